@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// collectHostMetrics publishes host memory and per-CPU utilization as gauges.
+// CPU utilization is measured since the previous call, so the first
+// collection reports usage since the collector started.
+func collectHostMetrics(store *metricsStore) error {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return fmt.Errorf("read memory stats: %w", err)
+	}
+	store.setGauge("TotalMemory", float64(vm.Total))
+	store.setGauge("FreeMemory", float64(vm.Free))
+
+	percents, err := cpu.Percent(0, true)
+	if err != nil {
+		return fmt.Errorf("read cpu stats: %w", err)
+	}
+	for i, p := range percents {
+		store.setGauge(fmt.Sprintf("CPUutilization%d", i+1), p)
+	}
+
+	return nil
+}
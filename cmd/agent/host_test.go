@@ -0,0 +1,28 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCollectHostMetrics(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host metrics are verified on Linux only")
+	}
+	store := newMetricsStore()
+
+	if err := collectHostMetrics(store); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+
+	gauges, _ := store.reserve()
+	if gauges["TotalMemory"] <= 0 {
+		t.Fatalf("expected positive TotalMemory, got %v", gauges["TotalMemory"])
+	}
+	if _, ok := gauges["FreeMemory"]; !ok {
+		t.Fatalf("FreeMemory not collected")
+	}
+	if _, ok := gauges["CPUutilization1"]; !ok {
+		t.Fatalf("CPUutilization1 not collected: %v", gauges)
+	}
+}
@@ -1,66 +1,30 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"os/signal"
 	"runtime"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/xGuthub/metrics-collection-service/internal/config"
-	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/encryption"
+	"github.com/xGuthub/metrics-collection-service/internal/tlsutil"
 )
 
 const (
 	httpTimeout = 5 * time.Second
+	// drainTimeout bounds how long queued reports may be sent on shutdown.
+	drainTimeout = 10 * time.Second
+	// flushTimeout bounds the final report made on shutdown.
+	flushTimeout = 5 * time.Second
 )
 
-type metricsStore struct {
-	mu       sync.RWMutex
-	gauges   map[string]float64
-	counters map[string]int64
-}
-
-func newMetricsStore() *metricsStore {
-	return &metricsStore{
-		gauges:   make(map[string]float64),
-		counters: make(map[string]int64),
-	}
-}
-
-func (s *metricsStore) setGauge(name string, value float64) {
-	s.mu.Lock()
-	s.gauges[name] = value
-	s.mu.Unlock()
-}
-
-func (s *metricsStore) incCounter(name string, delta int64) {
-	s.mu.Lock()
-	s.counters[name] += delta
-	s.mu.Unlock()
-}
-
-func (s *metricsStore) getSnapshot() (map[string]float64, map[string]int64) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	g := make(map[string]float64, len(s.gauges))
-	for k, v := range s.gauges {
-		g[k] = v
-	}
-	c := make(map[string]int64, len(s.counters))
-	for k, v := range s.counters {
-		c[k] = v
-	}
-
-	return g, c
-}
-
 func collectRuntimeMetrics(store *metricsStore) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
@@ -98,134 +62,113 @@ func collectRuntimeMetrics(store *metricsStore) {
 	store.setGauge("RandomValue", rand.Float64())
 }
 
-func reportMetrics(ctx context.Context, client *resty.Client, store *metricsStore, baseURL string) {
-	gauges, counters := store.getSnapshot()
-
-	// Endpoint for JSON updates
-	url := fmt.Sprintf("%s/update/", baseURL)
-
-	// Send gauges as JSON one by one
-	for name, val := range gauges {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		v := val // create addressable copy
-		payload := models.Metrics{ID: name, MType: models.Gauge, Value: &v}
-
-		// Marshal and gzip the payload
-		body, err := gzipJSON(payload)
-		if err != nil {
-			log.Printf("prepare gauge %s failed: %v", name, err)
-			continue
-		}
-
-		_, err = client.R().
-			SetContext(ctx).
-			SetHeader("Content-Type", "application/json").
-			SetHeader("Content-Encoding", "gzip").
-			// Accept-Encoding is automatically handled by net/http, but setting explicitly is okay
-			SetHeader("Accept-Encoding", "gzip").
-			SetBody(body).
-			Post(url)
-		if err != nil {
-			log.Printf("report gauge %s failed: %v", name, err)
-
-			continue
-		}
-		log.Printf("report gauge %s success", name)
+func main() {
+	// Load config from flags: -a, -r, -p, -l
+	cfg, err := config.LoadAgentConfigFromFlags()
+	if err != nil {
+		log.Fatalf("failed to parse flags: %v", err)
 	}
 
-	// Send counters as JSON one by one
-	for name, val := range counters {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
 
-		d := val // create addressable copy
-		payload := models.Metrics{ID: name, MType: models.Counter, Delta: &d}
+	if err := run(ctx, cfg); err != nil {
+		stop()
+		log.Fatalf("%v", err)
+	}
+	log.Printf("agent stopped")
+}
 
-		// Marshal and gzip the payload
-		body, err := gzipJSON(payload)
+// run collects and reports metrics until ctx is done, then drains queued
+// reports and makes one final bounded flush of everything still pending.
+func run(ctx context.Context, cfg *config.AgentConfig) error {
+	client := resty.New().SetTimeout(httpTimeout)
+	scheme := "http"
+	if cfg.UseTLS {
+		tlsCfg, err := tlsutil.ClientConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile)
 		if err != nil {
-			log.Printf("prepare counter %s failed: %v", name, err)
-			continue
+			return fmt.Errorf("configure TLS: %w", err)
 		}
+		client.SetTLSClientConfig(tlsCfg)
+		scheme = "https"
+	}
 
-		_, err = client.R().
-			SetContext(ctx).
-			SetHeader("Content-Type", "application/json").
-			SetHeader("Content-Encoding", "gzip").
-			SetHeader("Accept-Encoding", "gzip").
-			SetBody(body).
-			Post(url)
+	store := newMetricsStore()
+	rep := &reporter{
+		client:      client,
+		store:       store,
+		url:         fmt.Sprintf("%s://%s/updates/", scheme, cfg.Address),
+		key:         cfg.Key,
+		retryDelays: cfg.RetryDelays,
+	}
+	if cfg.CryptoKey != "" {
+		pub, err := encryption.LoadPublicKey(cfg.CryptoKey)
 		if err != nil {
-			log.Printf("report counter %s failed: %v", name, err)
-
-			continue
+			return fmt.Errorf("load crypto key: %w", err)
 		}
-		log.Printf("report counter %s success", name)
+		rep.publicKey = pub
 	}
-}
-
-// gzipJSON marshals v to JSON and gzips it.
-func gzipJSON(v any) ([]byte, error) {
-	// Marshal to JSON
-	b, err := json.Marshal(v)
-	if err != nil {
-		return nil, err
-	}
-	// Compress
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	if _, err := zw.Write(b); err != nil {
-		zw.Close()
-		return nil, err
+	if ip, err := outboundIP(cfg.Address); err != nil {
+		log.Printf("failed to detect outbound address, X-Real-IP will not be sent: %v", err)
+	} else {
+		rep.realIP = ip
 	}
-	if err := zw.Close(); err != nil {
-		return nil, err
+
+	// Initial collection and counters init
+	collectRuntimeMetrics(store)
+	store.incCounter("PollCount", 1)
+	if err := collectHostMetrics(store); err != nil {
+		log.Printf("collect host metrics: %v", err)
 	}
-	return buf.Bytes(), nil
-}
 
-func main() {
-	// Load config from flags: -a, -r, -p
-	cfg, err := config.LoadAgentConfigFromFlags()
-	if err != nil {
-		log.Fatalf("failed to parse flags: %v", err)
+	// Senders use their own context so queued reports can be drained on shutdown
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+
+	jobs := make(chan *reportJob, cfg.RateLimit)
+
+	var collectors sync.WaitGroup
+	collectors.Add(3)
+	go func() {
+		defer collectors.Done()
+		runPoller(ctx, cfg.PollInterval, store)
+	}()
+	go func() {
+		defer collectors.Done()
+		runHostPoller(ctx, cfg.PollInterval, store)
+	}()
+	go func() {
+		defer collectors.Done()
+		runReporter(ctx, cfg.ReportInterval, rep, jobs)
+	}()
+
+	var senders sync.WaitGroup
+	for i := 0; i < cfg.RateLimit; i++ {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			runSender(sendCtx, rep, jobs)
+		}()
 	}
 
-	store := newMetricsStore()
-	client := resty.New().SetTimeout(httpTimeout)
+	<-ctx.Done()
+	log.Printf("shutting down, flushing metrics")
 
-	// Initial collection and counters init
-	collectRuntimeMetrics(store)
-	store.incCounter("PollCount", 1)
+	// Producers stop first, then the queue is closed and drained by the senders
+	collectors.Wait()
+	close(jobs)
+	if !waitTimeout(&senders, drainTimeout) {
+		log.Printf("reports still in flight after %s, aborting", drainTimeout)
+		cancelSend()
+		senders.Wait()
+	}
 
-	// Tickers
-	pollTicker := time.NewTicker(cfg.PollInterval)
-	reportTicker := time.NewTicker(cfg.ReportInterval)
-	defer pollTicker.Stop()
-	defer reportTicker.Stop()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	baseURL := fmt.Sprintf("http://%s", cfg.Address)
-
-	for {
-		select {
-		case <-pollTicker.C:
-			collectRuntimeMetrics(store)
-			store.incCounter("PollCount", 1)
-		case <-reportTicker.C:
-			reportMetrics(ctx, client, store, baseURL)
-		case <-ctx.Done():
-			return
-		}
+	// Whatever failed or was collected after the last report goes out now
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	if err := rep.report(flushCtx); err != nil {
+		return fmt.Errorf("final flush failed: %w", err)
 	}
+
+	return nil
 }
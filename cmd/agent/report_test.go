@@ -0,0 +1,156 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// fakeServer records counter deltas received on /updates/ and can be told
+// to fail the next requests with the given status.
+type fakeServer struct {
+	mu       sync.Mutex
+	failWith []int
+	deltas   []map[string]int64
+	received map[string]int64
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.failWith) > 0 {
+		code := f.failWith[0]
+		f.failWith = f.failWith[1:]
+		w.WriteHeader(code)
+
+		return
+	}
+
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+	var batch []models.Metrics
+	if err := json.NewDecoder(zr).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	got := make(map[string]int64)
+	for _, m := range batch {
+		if m.MType == models.Counter && m.Delta != nil {
+			got[m.ID] += *m.Delta
+			f.received[m.ID] += *m.Delta
+		}
+	}
+	f.deltas = append(f.deltas, got)
+	w.WriteHeader(http.StatusOK)
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+	f := &fakeServer{received: make(map[string]int64)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	return f, srv.URL
+}
+
+func newTestReporter(url string, store *metricsStore) *reporter {
+	return &reporter{client: resty.New(), store: store, url: url + "/updates/"}
+}
+
+func TestReportMetrics_SendsDeltasOnly(t *testing.T) {
+	f, url := newFakeServer(t)
+	store := newMetricsStore()
+	rep := newTestReporter(url, store)
+
+	store.incCounter("PollCount", 1)
+	store.incCounter("PollCount", 1)
+	if err := rep.report(context.Background()); err != nil {
+		t.Fatalf("first report: %v", err)
+	}
+
+	store.incCounter("PollCount", 1)
+	if err := rep.report(context.Background()); err != nil {
+		t.Fatalf("second report: %v", err)
+	}
+
+	// Nothing new: counters must not be resent
+	if err := rep.report(context.Background()); err != nil {
+		t.Fatalf("third report: %v", err)
+	}
+
+	if len(f.deltas) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(f.deltas))
+	}
+	if f.deltas[0]["PollCount"] != 2 || f.deltas[1]["PollCount"] != 1 {
+		t.Fatalf("unexpected deltas: %v", f.deltas)
+	}
+	if f.received["PollCount"] != 3 {
+		t.Fatalf("server total must equal number of polls, got %d", f.received["PollCount"])
+	}
+}
+
+func TestReportMetrics_KeepsDeltasOnFailure(t *testing.T) {
+	f, url := newFakeServer(t)
+	f.failWith = []int{http.StatusInternalServerError}
+	store := newMetricsStore()
+	rep := newTestReporter(url, store)
+
+	store.incCounter("PollCount", 2)
+	if err := rep.report(context.Background()); err == nil {
+		t.Fatalf("expected failed report")
+	}
+
+	store.incCounter("PollCount", 1)
+	if err := rep.report(context.Background()); err != nil {
+		t.Fatalf("second report: %v", err)
+	}
+
+	if len(f.deltas) != 1 || f.deltas[0]["PollCount"] != 3 {
+		t.Fatalf("expected unacknowledged delta to be resent, got %v", f.deltas)
+	}
+}
+
+func TestMetricsStore_IncrementDuringReport(t *testing.T) {
+	store := newMetricsStore()
+
+	store.incCounter("c", 5)
+	_, sent := store.reserve()
+	// Increment arrives while the report is in flight
+	store.incCounter("c", 2)
+
+	// A concurrent report must not pick up the in-flight delta again
+	if _, again := store.reserve(); again["c"] != 2 {
+		t.Fatalf("expected only new delta 2 to be reserved, got %v", again)
+	}
+
+	store.ack(sent)
+	store.release(map[string]int64{"c": 2})
+
+	if _, c := store.reserve(); c["c"] != 2 {
+		t.Fatalf("expected pending delta 2 after ack, got %v", c)
+	}
+}
+
+func TestOutboundIP(t *testing.T) {
+	ip, err := outboundIP("127.0.0.1:8080")
+	if err != nil {
+		t.Fatalf("outboundIP: %v", err)
+	}
+	if ip != "127.0.0.1" {
+		t.Fatalf("expected loopback address, got %q", ip)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/xGuthub/metrics-collection-service/internal/encryption"
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/sign"
+)
+
+// reporter turns the store contents into batches and delivers them to the server.
+type reporter struct {
+	client      *resty.Client
+	store       *metricsStore
+	url         string
+	key         string
+	retryDelays []time.Duration
+	// publicKey, when set, encrypts every gzipped batch for the server.
+	publicKey *rsa.PublicKey
+	// realIP is sent as X-Real-IP so the server can check the trusted subnet.
+	realIP string
+}
+
+// reportJob is a prepared batch waiting to be sent.
+type reportJob struct {
+	body []byte
+	size int
+	// counters are the deltas reserved in the store for this batch;
+	// they are acknowledged or released once sending is done.
+	counters map[string]int64
+}
+
+// report prepares a batch and sends it right away.
+func (rep *reporter) report(ctx context.Context) error {
+	job, err := rep.prepare()
+	if err != nil || job == nil {
+		return err
+	}
+
+	return rep.send(ctx, job)
+}
+
+// prepare reserves pending metrics and builds a gzipped batch of them.
+// It returns nil when there is nothing to report.
+func (rep *reporter) prepare() (*reportJob, error) {
+	gauges, counters := rep.store.reserve()
+
+	batch := make([]models.Metrics, 0, len(gauges)+len(counters))
+	for name, val := range gauges {
+		v := val // create addressable copy
+		m := models.Metrics{ID: name, MType: models.Gauge, Value: &v}
+		if rep.key != "" {
+			m.Hash = sign.Metric(rep.key, m)
+		}
+		batch = append(batch, m)
+	}
+	for name, val := range counters {
+		d := val // create addressable copy
+		m := models.Metrics{ID: name, MType: models.Counter, Delta: &d}
+		if rep.key != "" {
+			m.Hash = sign.Metric(rep.key, m)
+		}
+		batch = append(batch, m)
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	// Marshal and gzip the whole batch
+	body, err := gzipJSON(batch)
+	if err == nil && rep.publicKey != nil {
+		body, err = encryption.Encrypt(rep.publicKey, body)
+	}
+	if err != nil {
+		rep.store.release(counters)
+
+		return nil, fmt.Errorf("prepare batch: %w", err)
+	}
+
+	return &reportJob{body: body, size: len(batch), counters: counters}, nil
+}
+
+// send delivers a prepared batch with retries and settles its reserved deltas.
+func (rep *reporter) send(ctx context.Context, job *reportJob) error {
+	err := withRetry(ctx, rep.retryDelays, func() error {
+		return rep.sendBatch(ctx, job.body)
+	})
+	if err != nil {
+		// Keep the deltas pending, they go out with the next report
+		rep.store.release(job.counters)
+
+		return fmt.Errorf("report batch of %d metrics: %w", job.size, err)
+	}
+	rep.store.ack(job.counters)
+	log.Printf("report batch of %d metrics success", job.size)
+
+	return nil
+}
+
+// sendBatch posts a prepared (gzipped) batch once. Non-2xx answers are
+// returned as *statusError so the caller can decide whether to retry.
+func (rep *reporter) sendBatch(ctx context.Context, body []byte) error {
+	req := rep.client.R()
+	if rep.key != "" {
+		// Sign the body exactly as it goes over the wire
+		req.SetHeader(sign.Header, sign.Sum(rep.key, body))
+	}
+	if rep.publicKey != nil {
+		req.SetHeader(encryption.Header, encryption.Scheme)
+	}
+	if rep.realIP != "" {
+		req.SetHeader("X-Real-IP", rep.realIP)
+	}
+
+	resp, err := req.
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Encoding", "gzip").
+		// Accept-Encoding is automatically handled by net/http, but setting explicitly is okay
+		SetHeader("Accept-Encoding", "gzip").
+		SetBody(body).
+		Post(rep.url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return &statusError{code: resp.StatusCode(), body: resp.String()}
+	}
+
+	return nil
+}
+
+// outboundIP returns the local address used to reach the server at addr (host:port).
+// Dialing UDP sends no packets, it only selects the route and interface.
+func outboundIP(addr string) (string, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	udpAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return "", fmt.Errorf("unexpected local address %v", conn.LocalAddr())
+	}
+
+	return udpAddr.IP.String(), nil
+}
+
+// gzipJSON marshals v to JSON and gzips it.
+func gzipJSON(v any) ([]byte, error) {
+	// Marshal to JSON
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Compress
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		zw.Close()
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// statusError is returned when the server answers with a non-2xx status.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s: %s", e.code, http.StatusText(e.code), e.body)
+}
+
+// isRetriable reports whether a failed request is worth another attempt:
+// connection refused/reset, timeouts and 5xx answers. Validation errors (4xx)
+// will fail the same way again, so they are not retried.
+func isRetriable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// withRetry calls fn and, while it fails with a retriable error, waits for the
+// next delay from delays and calls it again. It gives up once delays are used up
+// or ctx is done; the last error is returned.
+func withRetry(ctx context.Context, delays []time.Duration, fn func() error) error {
+	err := fn()
+	for _, d := range delays {
+		if !isRetriable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return err
+		case <-timer.C:
+		}
+
+		err = fn()
+	}
+
+	return err
+}
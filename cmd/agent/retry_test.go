@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetriable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection refused", fmt.Errorf("post: %w", syscall.ECONNREFUSED), true},
+		{"server error", &statusError{code: http.StatusBadGateway}, true},
+		{"validation error", &statusError{code: http.StatusBadRequest}, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetriable(tt.err); got != tt.want {
+			t.Fatalf("%s: isRetriable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetry_RetriesUntilSuccess(t *testing.T) {
+	delays := []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+
+	calls := 0
+	err := withRetry(context.Background(), delays, func() error {
+		calls++
+		if calls < 3 {
+			return &statusError{code: http.StatusServiceUnavailable}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestWithRetry_StopsOnNonRetriable(t *testing.T) {
+	delays := []time.Duration{time.Millisecond, time.Millisecond}
+
+	calls := 0
+	err := withRetry(context.Background(), delays, func() error {
+		calls++
+
+		return &statusError{code: http.StatusBadRequest}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected single failed call, got calls=%d err=%v", calls, err)
+	}
+}
+
+func TestWithRetry_GivesUpAfterDelays(t *testing.T) {
+	delays := []time.Duration{time.Millisecond, time.Millisecond}
+
+	calls := 0
+	err := withRetry(context.Background(), delays, func() error {
+		calls++
+
+		return syscall.ECONNREFUSED
+	})
+	if !errors.Is(err, syscall.ECONNREFUSED) || calls != 3 {
+		t.Fatalf("expected 3 failed calls, got calls=%d err=%v", calls, err)
+	}
+}
+
+func TestWithRetry_RespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	delays := []time.Duration{time.Hour}
+
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- withRetry(ctx, delays, func() error {
+			calls++
+
+			return syscall.ECONNREFUSED
+		})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil || calls != 1 {
+			t.Fatalf("expected single failed call, got calls=%d err=%v", calls, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("withRetry did not return after context cancellation")
+	}
+}
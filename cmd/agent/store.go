@@ -0,0 +1,88 @@
+package main
+
+import "sync"
+
+// metricsStore keeps the latest gauges and the counter deltas that the
+// server has not acknowledged yet.
+//
+// A report reserves the pending deltas, and they are removed only after
+// the server answers 2xx (ack). On failure the reservation is released and
+// the deltas are sent again with the next report. Increments made while a
+// report is in flight stay pending for the next one.
+type metricsStore struct {
+	mu       sync.RWMutex
+	gauges   map[string]float64
+	counters map[string]int64
+	// reserved holds deltas that are currently being sent.
+	reserved map[string]int64
+}
+
+func newMetricsStore() *metricsStore {
+	return &metricsStore{
+		gauges:   make(map[string]float64),
+		counters: make(map[string]int64),
+		reserved: make(map[string]int64),
+	}
+}
+
+func (s *metricsStore) setGauge(name string, value float64) {
+	s.mu.Lock()
+	s.gauges[name] = value
+	s.mu.Unlock()
+}
+
+func (s *metricsStore) incCounter(name string, delta int64) {
+	s.mu.Lock()
+	s.counters[name] += delta
+	s.mu.Unlock()
+}
+
+// reserve returns the current gauges and the counter deltas not yet
+// sent or in flight, and marks those deltas as in flight.
+func (s *metricsStore) reserve() (map[string]float64, map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := make(map[string]float64, len(s.gauges))
+	for k, v := range s.gauges {
+		g[k] = v
+	}
+	c := make(map[string]int64, len(s.counters))
+	for k, v := range s.counters {
+		d := v - s.reserved[k]
+		if d == 0 {
+			continue
+		}
+		c[k] = d
+		s.reserved[k] += d
+	}
+
+	return g, c
+}
+
+// ack removes deltas delivered to the server.
+func (s *metricsStore) ack(counters map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, d := range counters {
+		s.counters[k] -= d
+		s.reserved[k] -= d
+		if s.counters[k] == 0 {
+			delete(s.counters, k)
+		}
+		if s.reserved[k] == 0 {
+			delete(s.reserved, k)
+		}
+	}
+}
+
+// release returns deltas of a failed report back to pending.
+func (s *metricsStore) release(counters map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, d := range counters {
+		s.reserved[k] -= d
+		if s.reserved[k] == 0 {
+			delete(s.reserved, k)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// runPoller collects runtime metrics every interval until ctx is done.
+func runPoller(ctx context.Context, interval time.Duration, store *metricsStore) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			collectRuntimeMetrics(store)
+			store.incCounter("PollCount", 1)
+		}
+	}
+}
+
+// runHostPoller collects host system metrics every interval until ctx is done.
+func runHostPoller(ctx context.Context, interval time.Duration, store *metricsStore) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := collectHostMetrics(store); err != nil {
+				log.Printf("collect host metrics: %v", err)
+			}
+		}
+	}
+}
+
+// runReporter prepares a batch every interval and queues it for the senders.
+// A full queue blocks only the reporter, polling keeps going meanwhile.
+func runReporter(ctx context.Context, interval time.Duration, rep *reporter, jobs chan<- *reportJob) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job, err := rep.prepare()
+			if err != nil {
+				log.Printf("%v", err)
+
+				continue
+			}
+			if job == nil {
+				continue
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				rep.store.release(job.counters)
+
+				return
+			}
+		}
+	}
+}
+
+// runSender sends queued batches until the queue is closed.
+func runSender(ctx context.Context, rep *reporter, jobs <-chan *reportJob) {
+	for job := range jobs {
+		if err := rep.send(ctx, job); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+// waitTimeout waits for wg and reports whether it finished within d.
+func waitTimeout(wg *sync.WaitGroup, d time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
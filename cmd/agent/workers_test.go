@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/config"
+)
+
+func TestRunSender_DrainsQueueAfterClose(t *testing.T) {
+	f, url := newFakeServer(t)
+	store := newMetricsStore()
+	rep := newTestReporter(url, store)
+
+	jobs := make(chan *reportJob, 3)
+	for i := 0; i < 3; i++ {
+		store.incCounter("PollCount", 1)
+		job, err := rep.prepare()
+		if err != nil || job == nil {
+			t.Fatalf("prepare: job=%v err=%v", job, err)
+		}
+		jobs <- job
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runSender(context.Background(), rep, jobs)
+		}()
+	}
+	if !waitTimeout(&wg, 5*time.Second) {
+		t.Fatalf("senders did not drain the queue")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.deltas) != 3 || f.received["PollCount"] != 3 {
+		t.Fatalf("expected 3 batches with total 3, got %d batches and total %d", len(f.deltas), f.received["PollCount"])
+	}
+	if _, c := store.reserve(); len(c) != 0 {
+		t.Fatalf("expected no pending counters after drain, got %v", c)
+	}
+}
+
+func TestRun_FinalFlushOnShutdown(t *testing.T) {
+	f, url := newFakeServer(t)
+	cfg := &config.AgentConfig{
+		Address:        strings.TrimPrefix(url, "http://"),
+		ReportInterval: time.Hour,
+		PollInterval:   time.Hour,
+		RateLimit:      1,
+	}
+
+	// Already canceled: the agent collects once and must flush before returning
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := run(ctx, cfg); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.deltas) != 1 || f.received["PollCount"] != 1 {
+		t.Fatalf("expected one final batch with PollCount=1, got %v", f.deltas)
+	}
+}
+
+func TestRun_FinalFlushFailure(t *testing.T) {
+	f, url := newFakeServer(t)
+	f.failWith = []int{http.StatusBadRequest}
+	cfg := &config.AgentConfig{
+		Address:        strings.TrimPrefix(url, "http://"),
+		ReportInterval: time.Hour,
+		PollInterval:   time.Hour,
+		RateLimit:      1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := run(ctx, cfg); err == nil {
+		t.Fatalf("expected final flush error")
+	}
+}
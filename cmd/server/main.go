@@ -2,19 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/xGuthub/metrics-collection-service/internal/alert"
 	"github.com/xGuthub/metrics-collection-service/internal/config"
+	"github.com/xGuthub/metrics-collection-service/internal/encryption"
 	"github.com/xGuthub/metrics-collection-service/internal/handler"
 	"github.com/xGuthub/metrics-collection-service/internal/logger"
 	"github.com/xGuthub/metrics-collection-service/internal/repository"
 	"github.com/xGuthub/metrics-collection-service/internal/service"
+	"github.com/xGuthub/metrics-collection-service/internal/tlsutil"
+	"github.com/xGuthub/metrics-collection-service/migrations"
 )
 
 func main() {
@@ -27,15 +34,64 @@ func main() {
 		logger.Log.Fatalf("failed to parse flags: %v", err)
 	}
 
-	storage := repository.NewMemStorage()
+	var storage service.Storage
+	if srvCfg.DatabaseDSN != "" {
+		pgCtx, pgCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		pgStorage, err := repository.NewPostgresStorage(pgCtx, srvCfg.DatabaseDSN, migrations.FS)
+		pgCancel()
+		if err != nil {
+			logger.Log.Fatalf("failed to init database storage: %v", err)
+		}
+		defer pgStorage.Close()
+		storage = pgStorage
+	} else {
+		storage = repository.NewMemStorage()
+	}
+
 	metricsService := service.NewMetricsService(storage)
-	metricsService.SetStateStore(repository.NewFileStateStore())
-	// Configure persistence based on server config
-	metricsService.ConfigurePersistence(service.PersistenceConfig{
-		FilePath:      srvCfg.FileStoragePath,
-		StoreInterval: srvCfg.StoreIntervale,
-		Restore:       srvCfg.Restore,
-	})
+	if types := metricsService.UnsupportedMetricTypes(); len(types) > 0 {
+		logger.Log.Warnf("storage does not support %s metrics, their writes are rejected", strings.Join(types, ", "))
+	}
+	metricsService.SetSignKey(srvCfg.Key)
+	if len(srvCfg.HistogramBuckets) > 0 {
+		metricsService.SetDefaultBuckets(srvCfg.HistogramBuckets)
+	}
+	metricsService.SetSummaryMaxAge(srvCfg.SummaryMaxAge)
+	metricsService.SetSetPrecision(uint8(srvCfg.SetPrecision))
+	if srvCfg.HistoryRetention > 0 {
+		if srvCfg.HistoryEngine == "gorilla" {
+			metricsService.SetHistory(repository.NewGorillaHistory(srvCfg.HistoryRetention, srvCfg.HistorySamples))
+		} else {
+			metricsService.SetHistory(repository.NewMemHistory(srvCfg.HistoryRetention, srvCfg.HistorySamples))
+		}
+		metricsService.SetHistoryErrorHandler(func(err error) {
+			logger.Log.Warnf("history sample dropped: %v", err)
+		})
+	}
+	if len(srvCfg.AnomalyMetrics) > 0 {
+		metricsService.SetAnomalyDetection(service.AnomalyConfig{
+			Metrics: srvCfg.AnomalyMetrics,
+			Alpha:   srvCfg.AnomalyAlpha,
+			K:       srvCfg.AnomalyK,
+		})
+	}
+	// The database keeps state itself, the file store is only used without it
+	if srvCfg.DatabaseDSN == "" {
+		stateStore := repository.NewFileStateStore()
+		stateStore.SetCompression(repository.Compression(srvCfg.SnapshotCompression))
+		stateStore.SetRetention(srvCfg.SnapshotRetention)
+		stateStore.SetGenerationInterval(srvCfg.SnapshotGenerationInterval)
+		stateStore.SetErrorHandler(func(err error) {
+			logger.Log.Errorf("state file error: %v", err)
+		})
+		metricsService.SetStateStore(stateStore)
+		// Configure persistence based on server config
+		metricsService.ConfigurePersistence(service.PersistenceConfig{
+			FilePath:      srvCfg.FileStoragePath,
+			StoreInterval: srvCfg.StoreIntervale,
+			Restore:       srvCfg.Restore,
+		})
+	}
 
 	// Restore state on start if enabled
 	if err := metricsService.RestoreState(); err != nil {
@@ -43,14 +99,88 @@ func main() {
 	}
 	metricsHandler := handler.NewMetricsHandler(metricsService)
 
+	var evaluator *alert.Evaluator
+	if srvCfg.AlertRulesFile != "" {
+		alertCfg, err := alert.LoadConfig(srvCfg.AlertRulesFile)
+		if err != nil {
+			logger.Log.Fatalf("failed to load alert rules: %v", err)
+		}
+		evaluator = alert.NewEvaluator(alertCfg.Rules, metricsService)
+		evaluator.SetInhibitRules(alertCfg.InhibitRules)
+		evaluator.SetErrorHandler(func(err error) {
+			logger.Log.Errorf("alert evaluation error: %v", err)
+		})
+		// Silences are kept next to the state snapshot to survive restarts
+		silences := alert.NewSilences()
+		if srvCfg.FileStoragePath != "" {
+			silences, err = alert.LoadSilences(alert.SilencesPath(srvCfg.FileStoragePath), time.Now())
+			if err != nil {
+				logger.Log.Fatalf("failed to load silences: %v", err)
+			}
+		}
+		evaluator.SetSilences(silences)
+		metricsHandler.SetSilences(silences)
+		if len(alertCfg.Notifiers) > 0 {
+			channels, err := alertCfg.Channels()
+			if err != nil {
+				logger.Log.Fatalf("failed to configure alert notifiers: %v", err)
+			}
+			dispatcher := alert.NewDispatcher(alertCfg.Rules, channels)
+			dispatcher.SetErrorHandler(func(err error) {
+				logger.Log.Errorf("alert notification error: %v", err)
+			})
+			evaluator.SetDispatcher(dispatcher)
+		}
+		metricsHandler.SetAlerts(evaluator)
+		logger.Log.Infof("loaded %d alert rules and %d notifiers", len(alertCfg.Rules), len(alertCfg.Notifiers))
+	}
+
+	var privateKey *rsa.PrivateKey
+	if srvCfg.CryptoKey != "" {
+		privateKey, err = encryption.LoadPrivateKey(srvCfg.CryptoKey)
+		if err != nil {
+			logger.Log.Fatalf("failed to load crypto key: %v", err)
+		}
+	}
+
+	var trustedSubnet *net.IPNet
+	if srvCfg.TrustedSubnet != "" {
+		// Already validated by config
+		_, trustedSubnet, _ = net.ParseCIDR(srvCfg.TrustedSubnet)
+	}
+
 	r := chi.NewRouter()
 	r.Use(WithLogging)
+	r.Use(WithHash(srvCfg.Key))
+	r.Use(WithDecrypt(privateKey))
 	r.Use(WithGzip)
 	r.Get("/", metricsHandler.HomeHandler)
-	r.Post("/update/", metricsHandler.UpdateJSONHandler)
-	r.Post("/update/*", metricsHandler.UpdateHandler)
+	r.Get("/assets/*", metricsHandler.AssetsHandler)
+	// Only write and admin routes are limited to the trusted subnet
+	r.Group(func(r chi.Router) {
+		r.Use(WithTrustedSubnet(trustedSubnet))
+		r.Group(func(r chi.Router) {
+			// With a key configured metric writes must be signed,
+			// with a private key they must be encrypted
+			r.Use(WithSignatureRequired(srvCfg.Key))
+			r.Use(WithEncryptionRequired(privateKey))
+			r.Post("/update/", metricsHandler.UpdateJSONHandler)
+			r.Post("/update/*", metricsHandler.UpdateHandler)
+			r.Post("/updates/", metricsHandler.UpdatesJSONHandler)
+		})
+		r.Post("/api/v1/silences", metricsHandler.CreateSilenceHandler)
+		r.Delete("/api/v1/silences/*", metricsHandler.DeleteSilenceHandler)
+		r.Get("/api/v1/admin/snapshots", metricsHandler.SnapshotsHandler)
+		r.Post("/api/v1/admin/snapshots/*", metricsHandler.RestoreSnapshotHandler)
+	})
 	r.Post("/value/", metricsHandler.ValueJSONHandler)
 	r.Get("/value/*", metricsHandler.ValueHandler)
+	r.Get("/metrics", metricsHandler.PrometheusHandler)
+	r.Get("/api/v1/history/*", metricsHandler.HistoryHandler)
+	r.Get("/api/v1/alerts", metricsHandler.AlertsHandler)
+	r.Get("/api/v1/silences", metricsHandler.SilencesHandler)
+	r.Get("/api/v1/anomalies", metricsHandler.AnomaliesHandler)
+	r.Get("/ping", metricsHandler.PingHandler)
 
 	server := &http.Server{
 		Addr:              srvCfg.Address,
@@ -65,10 +195,29 @@ func main() {
 	metricsService.StartAutoSave(ctx, func(err error) {
 		logger.Log.Errorf("autosave error: %v", err)
 	})
+	if evaluator != nil {
+		go evaluator.Run(ctx, srvCfg.AlertInterval)
+	}
+
+	if srvCfg.TLSCertFile != "" {
+		tlsCfg, err := tlsutil.ServerConfig(srvCfg.TLSCertFile, srvCfg.TLSKeyFile, srvCfg.TLSClientCAFile)
+		if err != nil {
+			logger.Log.Fatalf("failed to configure TLS: %v", err)
+		}
+		server.TLSConfig = tlsCfg
+	}
 
 	go func() {
-		logger.Log.Infof("metrics server listening on http://%s", server.Addr)
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if server.TLSConfig != nil {
+			logger.Log.Infof("metrics server listening on https://%s", server.Addr)
+			// Certificates are already loaded into TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			logger.Log.Infof("metrics server listening on http://%s", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Log.Fatalf("server error: %v", err)
 		}
 	}()
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/rsa"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/xGuthub/metrics-collection-service/internal/encryption"
 	"github.com/xGuthub/metrics-collection-service/internal/logger"
+	"github.com/xGuthub/metrics-collection-service/internal/sign"
 )
 
 type (
@@ -150,3 +158,177 @@ func (c *combinedReadCloser) Close() error {
 
 	return firstErr
 }
+
+// hashResponseWriter buffers the response so it can be signed before sending.
+type hashResponseWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+func (hrw *hashResponseWriter) WriteHeader(statusCode int) {
+	if hrw.status == 0 {
+		hrw.status = statusCode
+	}
+}
+
+func (hrw *hashResponseWriter) Write(b []byte) (int, error) {
+	if hrw.status == 0 {
+		hrw.status = http.StatusOK
+	}
+
+	return hrw.buf.Write(b)
+}
+
+// WithHash verifies the HashSHA256 header of incoming requests and signs responses.
+// The signature covers the body exactly as it is sent over the wire,
+// so it must run before WithGzip decodes the request.
+// Requests without the header are passed through, WithSignatureRequired
+// rejects them on write routes; a mismatch is rejected with 400.
+func WithHash(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sum := r.Header.Get(sign.Header); sum != "" {
+				body, err := io.ReadAll(r.Body)
+				_ = r.Body.Close()
+				if err != nil {
+					http.Error(w, "failed to read body", http.StatusBadRequest)
+
+					return
+				}
+				if !sign.Verify(key, body, sum) {
+					http.Error(w, "hash mismatch", http.StatusBadRequest)
+
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			hrw := &hashResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(hrw, r)
+
+			if hrw.status == 0 {
+				hrw.status = http.StatusOK
+			}
+			w.Header().Set(sign.Header, sign.Sum(key, hrw.buf.Bytes()))
+			w.WriteHeader(hrw.status)
+			_, _ = w.Write(hrw.buf.Bytes())
+		})
+	}
+}
+
+// WithSignatureRequired rejects requests without the HashSHA256 header with 400
+// when a key is set. WithHash has verified the header by the time it runs.
+func WithSignatureRequired(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(sign.Header) == "" {
+				http.Error(w, "missing hash", http.StatusBadRequest)
+
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxSealedBody limits encrypted request bodies, which are read whole.
+const maxSealedBody = 32 << 20
+
+// decryptedKey marks the context of requests WithDecrypt has decrypted.
+type decryptedKey struct{}
+
+// WithDecrypt decrypts request bodies sealed by the agent with the server public key.
+// It must run before WithGzip, as the agent encrypts the already gzipped body.
+// Requests without the encryption header are passed through unchanged,
+// WithEncryptionRequired rejects them on write routes.
+func WithDecrypt(priv *rsa.PrivateKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme := r.Header.Get(encryption.Header)
+			if scheme == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			if priv == nil || scheme != encryption.Scheme {
+				http.Error(w, "unsupported encryption", http.StatusBadRequest)
+
+				return
+			}
+
+			sealed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSealedBody))
+			_ = r.Body.Close()
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+
+					return
+				}
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+
+				return
+			}
+			body, err := encryption.Decrypt(priv, sealed)
+			if err != nil {
+				http.Error(w, "failed to decrypt body", http.StatusBadRequest)
+
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			r.Header.Del(encryption.Header)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), decryptedKey{}, true)))
+		})
+	}
+}
+
+// WithEncryptionRequired rejects requests WithDecrypt has not decrypted with 400
+// when a private key is set.
+func WithEncryptionRequired(priv *rsa.PrivateKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if priv == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if decrypted, _ := r.Context().Value(decryptedKey{}).(bool); !decrypted {
+				http.Error(w, "encryption required", http.StatusBadRequest)
+
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithTrustedSubnet allows requests only from agents whose X-Real-IP belongs to subnet.
+// A missing or unparsable header is rejected as well. A nil subnet disables the check.
+func WithTrustedSubnet(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if subnet == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+			if ip == nil || !subnet.Contains(ip) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
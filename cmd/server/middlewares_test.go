@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xGuthub/metrics-collection-service/internal/encryption"
+	"github.com/xGuthub/metrics-collection-service/internal/sign"
+)
+
+// echoHandler answers with the request body it received.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+})
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWithHash(t *testing.T) {
+	h := WithHash("secret")(echoHandler)
+	body := []byte(`[{"id":"a","type":"gauge","value":1}]`)
+
+	// Valid signature: passed through and the response is signed
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	req.Header.Set(sign.Header, sign.Sum("secret", body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), body) {
+		t.Fatalf("valid signature: got %d %q", rr.Code, rr.Body.String())
+	}
+	if !sign.Verify("secret", rr.Body.Bytes(), rr.Header().Get(sign.Header)) {
+		t.Fatalf("response signature does not verify")
+	}
+
+	// Wrong signature
+	req2 := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	req2.Header.Set(sign.Header, sign.Sum("other", body))
+	rr2 := httptest.NewRecorder()
+	h.ServeHTTP(rr2, req2)
+	if rr2.Code != http.StatusBadRequest {
+		t.Fatalf("wrong signature: expected %d, got %d", http.StatusBadRequest, rr2.Code)
+	}
+}
+
+func TestWithSignatureRequired(t *testing.T) {
+	h := WithHash("secret")(WithSignatureRequired("secret")(echoHandler))
+	body := []byte(`[{"id":"a","type":"counter","delta":1}]`)
+
+	// Unsigned writes are rejected once a key is configured
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unsigned: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	req.Header.Set(sign.Header, sign.Sum("secret", body))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("signed: expected %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// Without a key nothing is required
+	rr = httptest.NewRecorder()
+	WithSignatureRequired("")(echoHandler).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("no key: expected %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestWithDecrypt_BeforeGzip(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	h := WithDecrypt(priv)(WithGzip(echoHandler))
+	payload := []byte(`[{"id":"a","type":"gauge","value":1}]`)
+
+	// The agent gzips first and encrypts the gzipped body
+	sealed, err := encryption.Encrypt(&priv.PublicKey, gzipBytes(t, payload))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(sealed))
+	req.Header.Set(encryption.Header, encryption.Scheme)
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), payload) {
+		t.Fatalf("encrypted request: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Plain requests are passed through
+	req2 := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(payload))
+	rr2 := httptest.NewRecorder()
+	h.ServeHTTP(rr2, req2)
+	if rr2.Code != http.StatusOK || !bytes.Equal(rr2.Body.Bytes(), payload) {
+		t.Fatalf("plain request: got %d %q", rr2.Code, rr2.Body.String())
+	}
+
+	// Garbage marked as encrypted is rejected
+	req3 := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(payload))
+	req3.Header.Set(encryption.Header, encryption.Scheme)
+	rr3 := httptest.NewRecorder()
+	h.ServeHTTP(rr3, req3)
+	if rr3.Code != http.StatusBadRequest {
+		t.Fatalf("garbage: expected %d, got %d", http.StatusBadRequest, rr3.Code)
+	}
+}
+
+func TestWithEncryptionRequired(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	h := WithDecrypt(priv)(WithEncryptionRequired(priv)(echoHandler))
+	payload := []byte(`[{"id":"a","type":"counter","delta":1}]`)
+
+	// Plaintext writes are rejected once a private key is configured
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(payload)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("plaintext: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	sealed, err := encryption.Encrypt(&priv.PublicKey, payload)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(sealed))
+	req.Header.Set(encryption.Header, encryption.Scheme)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), payload) {
+		t.Fatalf("encrypted: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Sealed bodies are read whole, so their size is capped
+	req = httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(make([]byte, maxSealedBody+1)))
+	req.Header.Set(encryption.Header, encryption.Scheme)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized: expected %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+
+	// Without a key nothing is required
+	rr = httptest.NewRecorder()
+	WithEncryptionRequired(nil)(echoHandler).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("no key: expected %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestWithDecrypt_NoKey(t *testing.T) {
+	h := WithDecrypt(nil)(echoHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader([]byte("x")))
+	req.Header.Set(encryption.Header, encryption.Scheme)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d without a private key, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestWithTrustedSubnet(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	h := WithTrustedSubnet(subnet)(echoHandler)
+
+	tests := []struct {
+		realIP string
+		want   int
+	}{
+		{"10.0.0.15", http.StatusOK},
+		{"10.0.1.15", http.StatusForbidden},
+		{"", http.StatusForbidden},
+		{"not-an-ip", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Fatalf("X-Real-IP %q: expected %d, got %d", tt.realIP, tt.want, rr.Code)
+		}
+	}
+
+	// Without a subnet everyone is allowed
+	rr := httptest.NewRecorder()
+	WithTrustedSubnet(nil)(echoHandler).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/updates/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("disabled check: expected %d, got %d", http.StatusOK, rr.Code)
+	}
+}
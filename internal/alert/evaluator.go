@@ -0,0 +1,270 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// State is the lifecycle stage of an alert.
+type State string
+
+const (
+	// StatePending means the condition holds, but not yet for the rule's For.
+	StatePending State = "pending"
+	// StateFiring means the condition has held for at least For.
+	StateFiring State = "firing"
+	// StateResolved means a firing alert's condition stopped holding.
+	StateResolved State = "resolved"
+)
+
+// DefaultResolvedRetention is how long resolved alerts stay listed.
+const DefaultResolvedRetention = 15 * time.Minute
+
+// Alert is the state of one rule for one series.
+type Alert struct {
+	Rule       string            `json:"rule"`
+	Series     string            `json:"series"`
+	Metric     string            `json:"metric"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	State      State             `json:"state"`
+	Value      float64           `json:"value"`
+	Op         string            `json:"op"`
+	Threshold  float64           `json:"threshold"`
+	Severity   string            `json:"severity,omitempty"`
+	Summary    string            `json:"summary,omitempty"`
+	ActiveAt   time.Time         `json:"active_at"`
+	FiredAt    *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+	// SilencedBy is the ID of the silence muting the alert.
+	SilencedBy string `json:"silenced_by,omitempty"`
+	// InhibitedBy is the rule whose firing alert suppresses this one.
+	InhibitedBy string `json:"inhibited_by,omitempty"`
+}
+
+// Suppressed reports whether the alert is silenced or inhibited.
+func (a *Alert) Suppressed() bool {
+	return a.SilencedBy != "" || a.InhibitedBy != ""
+}
+
+// Source provides the current metric values, keyed by series key.
+type Source interface {
+	AllGauges() (map[string]float64, error)
+	AllCounters() (map[string]int64, error)
+}
+
+// Evaluator periodically checks rules against a Source and tracks alert states.
+type Evaluator struct {
+	mu                sync.RWMutex
+	rules             []Rule
+	source            Source
+	alerts            map[string]*Alert
+	resolvedRetention time.Duration
+	dispatcher        *Dispatcher
+	silences          *Silences
+	inhibitRules      []InhibitRule
+	onError           func(error)
+}
+
+func NewEvaluator(rules []Rule, source Source) *Evaluator {
+	return &Evaluator{
+		rules:             rules,
+		source:            source,
+		alerts:            make(map[string]*Alert),
+		resolvedRetention: DefaultResolvedRetention,
+	}
+}
+
+// SetDispatcher enables notifications: Run hands the alerts to d after each round.
+func (e *Evaluator) SetDispatcher(d *Dispatcher) {
+	e.dispatcher = d
+}
+
+// SetSilences enables muting alerts by the given silences.
+func (e *Evaluator) SetSilences(s *Silences) {
+	e.silences = s
+}
+
+// SetInhibitRules sets the rules by which firing alerts suppress others.
+func (e *Evaluator) SetInhibitRules(rules []InhibitRule) {
+	e.inhibitRules = rules
+}
+
+// SetErrorHandler sets a callback for rounds skipped because the Source failed.
+func (e *Evaluator) SetErrorHandler(onError func(error)) {
+	e.onError = onError
+}
+
+// Run evaluates the rules every interval until ctx is done.
+// Slow notifications delay the next round rather than overlap with it.
+func (e *Evaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	e.round(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.round(ctx, time.Now())
+		}
+	}
+}
+
+func (e *Evaluator) round(ctx context.Context, now time.Time) {
+	if err := e.Evaluate(now); err != nil && e.onError != nil {
+		e.onError(err)
+	}
+	if e.dispatcher != nil {
+		e.dispatcher.Dispatch(ctx, e.Alerts(), now)
+	}
+}
+
+// Evaluate runs one evaluation round at now. When the Source fails the
+// round is skipped, so alerts keep their states rather than resolve.
+func (e *Evaluator) Evaluate(now time.Time) error {
+	gauges, err := e.source.AllGauges()
+	if err != nil {
+		return fmt.Errorf("evaluate alert rules: %w", err)
+	}
+	counters, err := e.source.AllCounters()
+	if err != nil {
+		return fmt.Errorf("evaluate alert rules: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	active := make(map[string]bool)
+	for i := range e.rules {
+		rule := &e.rules[i]
+		var values map[string]float64
+		if rule.Type == models.Counter {
+			values = make(map[string]float64, len(counters))
+			for k, v := range counters {
+				values[k] = float64(v)
+			}
+		} else {
+			values = gauges
+		}
+
+		for key, v := range values {
+			name, labels, err := models.ParseSeriesKey(key)
+			if err != nil || name != rule.Metric || !hasLabels(labels, rule.Labels) || !rule.matches(v) {
+				continue
+			}
+			id := rule.Name + "\x00" + key
+			active[id] = true
+
+			a, ok := e.alerts[id]
+			if !ok || a.State == StateResolved {
+				a = &Alert{
+					Rule:      rule.Name,
+					Series:    key,
+					Metric:    name,
+					Labels:    labels,
+					State:     StatePending,
+					Op:        rule.Op,
+					Threshold: rule.Threshold,
+					Severity:  rule.Severity,
+					Summary:   rule.Summary,
+					ActiveAt:  now,
+				}
+				e.alerts[id] = a
+			}
+			a.Value = v
+			if a.State == StatePending && now.Sub(a.ActiveAt) >= rule.For {
+				firedAt := now
+				a.State = StateFiring
+				a.FiredAt = &firedAt
+			}
+		}
+	}
+
+	// Alerts whose condition no longer holds, including vanished series
+	for id, a := range e.alerts {
+		if active[id] {
+			continue
+		}
+		switch a.State {
+		case StatePending:
+			delete(e.alerts, id)
+		case StateFiring:
+			resolvedAt := now
+			a.State = StateResolved
+			a.ResolvedAt = &resolvedAt
+		case StateResolved:
+			if now.Sub(*a.ResolvedAt) >= e.resolvedRetention {
+				delete(e.alerts, id)
+			}
+		}
+	}
+
+	e.suppress(now)
+
+	return nil
+}
+
+// suppress marks silenced and inhibited alerts; both are recomputed every
+// round, so an alert is released as soon as its silence or source goes away.
+func (e *Evaluator) suppress(now time.Time) {
+	for _, a := range e.alerts {
+		a.SilencedBy, a.InhibitedBy = "", ""
+		if e.silences != nil {
+			a.SilencedBy = e.silences.Match(a, now)
+		}
+	}
+	if len(e.inhibitRules) == 0 {
+		return
+	}
+	for _, target := range e.alerts {
+		for i := range e.inhibitRules {
+			for _, source := range e.alerts {
+				// The smallest rule name keeps the answer stable across rounds
+				if e.inhibitRules[i].inhibits(source, target) &&
+					(target.InhibitedBy == "" || source.Rule < target.InhibitedBy) {
+					target.InhibitedBy = source.Rule
+				}
+			}
+		}
+	}
+}
+
+// Alerts returns copies of the current alerts: firing first, then pending,
+// then resolved; within a state by rule and series.
+func (e *Evaluator) Alerts() []Alert {
+	e.mu.RLock()
+	out := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		out = append(out, *a)
+	}
+	e.mu.RUnlock()
+
+	order := map[State]int{StateFiring: 0, StatePending: 1, StateResolved: 2}
+	sort.Slice(out, func(i, j int) bool {
+		if order[out[i].State] != order[out[j].State] {
+			return order[out[i].State] < order[out[j].State]
+		}
+		if out[i].Rule != out[j].Rule {
+			return out[i].Rule < out[j].Rule
+		}
+
+		return out[i].Series < out[j].Series
+	})
+
+	return out
+}
+
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,173 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	gauges   map[string]float64
+	counters map[string]int64
+	err      error
+}
+
+func (f *fakeSource) AllGauges() (map[string]float64, error) { return f.gauges, f.err }
+func (f *fakeSource) AllCounters() (map[string]int64, error) { return f.counters, f.err }
+
+func TestEvaluator_Lifecycle(t *testing.T) {
+	src := &fakeSource{gauges: map[string]float64{
+		`HeapAlloc{host="web01"}`: 10,
+		`HeapAlloc{host="web02"}`: 10,
+		"Other":                   100,
+	}}
+	rules := []Rule{{Name: "HighHeap", Metric: "HeapAlloc", Type: "gauge", Op: ">", Threshold: 50, For: time.Minute}}
+	e := NewEvaluator(rules, src)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e.Evaluate(start)
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Fatalf("expected no alerts, got %+v", alerts)
+	}
+
+	// Each series of the metric is tracked separately
+	src.gauges[`HeapAlloc{host="web01"}`] = 60
+	e.Evaluate(start.Add(10 * time.Second))
+	alerts := e.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StatePending || alerts[0].Labels["host"] != "web01" || alerts[0].Value != 60 {
+		t.Fatalf("expected one pending alert, got %+v", alerts)
+	}
+
+	e.Evaluate(start.Add(70 * time.Second))
+	alerts = e.Alerts()
+	if alerts[0].State != StateFiring || alerts[0].FiredAt == nil {
+		t.Fatalf("expected firing alert, got %+v", alerts)
+	}
+
+	src.gauges[`HeapAlloc{host="web01"}`] = 40
+	e.Evaluate(start.Add(80 * time.Second))
+	alerts = e.Alerts()
+	if alerts[0].State != StateResolved || alerts[0].ResolvedAt == nil {
+		t.Fatalf("expected resolved alert, got %+v", alerts)
+	}
+
+	// Resolved alerts are listed for a while, then forgotten
+	e.Evaluate(start.Add(80*time.Second + DefaultResolvedRetention))
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Fatalf("expected resolved alert to expire, got %+v", alerts)
+	}
+}
+
+func TestEvaluator_PendingResetsAndLabels(t *testing.T) {
+	src := &fakeSource{
+		gauges:   map[string]float64{`temp{room="a"}`: 90, `temp{room="b"}`: 90},
+		counters: map[string]int64{"PollCount": 0},
+	}
+	rules := []Rule{
+		{Name: "Hot", Metric: "temp", Type: "gauge", Labels: map[string]string{"room": "a"}, Op: ">=", Threshold: 80, For: time.Minute},
+		{Name: "NoPolls", Metric: "PollCount", Type: "counter", Op: "==", Threshold: 0},
+	}
+	e := NewEvaluator(rules, src)
+	start := time.Now()
+
+	e.Evaluate(start)
+	alerts := e.Alerts()
+	// A zero For fires immediately; firing alerts are listed first
+	if len(alerts) != 2 || alerts[0].Rule != "NoPolls" || alerts[0].State != StateFiring {
+		t.Fatalf("unexpected alerts: %+v", alerts)
+	}
+	if alerts[1].Series != `temp{room="a"}` || alerts[1].State != StatePending {
+		t.Fatalf("label matcher not applied: %+v", alerts[1])
+	}
+
+	// A pending alert whose condition stops holding is dropped, not resolved
+	src.gauges[`temp{room="a"}`] = 20
+	e.Evaluate(start.Add(30 * time.Second))
+	src.gauges[`temp{room="a"}`] = 90
+	e.Evaluate(start.Add(40 * time.Second))
+	e.Evaluate(start.Add(90 * time.Second))
+	for _, a := range e.Alerts() {
+		if a.Rule == "Hot" && a.State != StatePending {
+			t.Fatalf("pending timer must restart, got %+v", a)
+		}
+	}
+
+	// A vanished series resolves its firing alert
+	delete(src.counters, "PollCount")
+	e.Evaluate(start.Add(100 * time.Second))
+	for _, a := range e.Alerts() {
+		if a.Rule == "NoPolls" && a.State != StateResolved {
+			t.Fatalf("expected resolved alert for vanished series, got %+v", a)
+		}
+	}
+}
+
+func TestEvaluator_SilencesAndInhibition(t *testing.T) {
+	src := &fakeSource{gauges: map[string]float64{
+		`up{host="web01"}`:        0,
+		`up{host="web02"}`:        1,
+		`HeapAlloc{host="web01"}`: 90,
+		`HeapAlloc{host="web02"}`: 90,
+		`Load{host="web02"}`:      9,
+	}}
+	rules := []Rule{
+		{Name: "HostDown", Metric: "up", Type: "gauge", Op: "==", Threshold: 0},
+		{Name: "HighHeap", Metric: "HeapAlloc", Type: "gauge", Op: ">", Threshold: 50},
+		{Name: "HighLoad", Metric: "Load", Type: "gauge", Op: ">", Threshold: 5},
+	}
+	e := NewEvaluator(rules, src)
+	e.SetInhibitRules([]InhibitRule{{Source: "HostDown", Equal: []string{"host"}}})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	silences := NewSilences()
+	sl, err := silences.Add(Silence{Metric: "Lo*", EndsAt: now.Add(time.Hour)}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetSilences(silences)
+
+	e.Evaluate(now)
+	got := make(map[string]Alert)
+	for _, a := range e.Alerts() {
+		got[a.Series] = a
+	}
+	if a := got[`HeapAlloc{host="web01"}`]; a.InhibitedBy != "HostDown" || !a.Suppressed() {
+		t.Fatalf("alert of the down host must be inhibited: %+v", a)
+	}
+	if a := got[`up{host="web01"}`]; a.Suppressed() {
+		t.Fatalf("the inhibiting alert must not be suppressed: %+v", a)
+	}
+	if a := got[`HeapAlloc{host="web02"}`]; a.Suppressed() {
+		t.Fatalf("alert of another host must not be inhibited: %+v", a)
+	}
+	if a := got[`Load{host="web02"}`]; a.SilencedBy != sl.ID {
+		t.Fatalf("expected silenced alert, got %+v", a)
+	}
+
+	// Suppression ends with its cause
+	src.gauges[`up{host="web01"}`] = 1
+	e.Evaluate(now.Add(2 * time.Hour))
+	for _, a := range e.Alerts() {
+		if a.Suppressed() {
+			t.Fatalf("unexpected suppressed alert %+v", a)
+		}
+	}
+}
+
+func TestEvaluator_SourceErrorKeepsStates(t *testing.T) {
+	src := &fakeSource{gauges: map[string]float64{"temp": 90}}
+	rules := []Rule{{Name: "Hot", Metric: "temp", Type: "gauge", Op: ">", Threshold: 80}}
+	e := NewEvaluator(rules, src)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := e.Evaluate(start); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+
+	// A failing source must not look like the metric went away
+	src.err = errors.New("database is down")
+	if err := e.Evaluate(start.Add(time.Minute)); !errors.Is(err, src.err) {
+		t.Fatalf("expected the source error, got %v", err)
+	}
+	if alerts := e.Alerts(); len(alerts) != 1 || alerts[0].State != StateFiring {
+		t.Fatalf("expected the alert to keep firing, got %+v", alerts)
+	}
+}
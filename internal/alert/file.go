@@ -0,0 +1,68 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileConfig configures a local JSONL sink.
+type FileConfig struct {
+	Path string `yaml:"path"`
+}
+
+func (c *FileConfig) validate() error {
+	if c.Path == "" {
+		return errors.New("file path is required")
+	}
+
+	return nil
+}
+
+// fileRecord is one line of the sink.
+type fileRecord struct {
+	NotifiedAt time.Time `json:"notified_at"`
+	Alert
+}
+
+// FileNotifier appends one JSON line per alert to a file. The file is
+// reopened for every batch, so it can be rotated while the server runs.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Notify writes the whole batch with a single write.
+func (n *FileNotifier) Notify(_ context.Context, alerts []Alert) error {
+	now := time.Now().UTC()
+	var buf []byte
+	for _, a := range alerts {
+		line, err := json.Marshal(fileRecord{NotifiedAt: now, Alert: a})
+		if err != nil {
+			return err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
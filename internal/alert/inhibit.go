@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// InhibitRule suppresses alerts while an alert of the Source rule fires,
+// e.g. every alert of a host while its "host down" alert fires.
+// Inhibited alerts are still listed, but not sent to notifiers.
+type InhibitRule struct {
+	// Source is the name of the rule whose firing alerts inhibit others.
+	Source string `yaml:"source"`
+	// Target is a metric name pattern in path.Match syntax; all metrics when empty.
+	Target string `yaml:"target"`
+	// Equal lists labels the source and the target series must share.
+	Equal []string `yaml:"equal"`
+}
+
+func (r *InhibitRule) validate(rules map[string]bool) error {
+	if !rules[r.Source] {
+		return fmt.Errorf("unknown source rule %q", r.Source)
+	}
+	if r.Target != "" {
+		if _, err := path.Match(r.Target, ""); err != nil {
+			return fmt.Errorf("invalid target pattern %q", r.Target)
+		}
+	}
+	for _, name := range r.Equal {
+		if !models.ValidLabelName(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if r.Target == "" && len(r.Equal) == 0 {
+		return errors.New("target or equal is required")
+	}
+
+	return nil
+}
+
+// inhibits reports whether the firing alert source suppresses target.
+func (r *InhibitRule) inhibits(source, target *Alert) bool {
+	// An alert of the source rule never silences the source rule itself
+	if source.Rule != r.Source || source.State != StateFiring || target.Rule == r.Source {
+		return false
+	}
+	if r.Target != "" {
+		if ok, _ := path.Match(r.Target, target.Metric); !ok {
+			return false
+		}
+	}
+	for _, name := range r.Equal {
+		if source.Labels[name] != target.Labels[name] {
+			return false
+		}
+	}
+
+	return true
+}
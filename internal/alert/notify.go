@@ -0,0 +1,269 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Notification defaults, used when a notifier or rule leaves them unset.
+const (
+	DefaultRepeatInterval = 4 * time.Hour
+	DefaultRetries        = 3
+	DefaultRetryBackoff   = time.Second
+	DefaultNotifyTimeout  = 10 * time.Second
+)
+
+// Notifier delivers a batch of firing and resolved alerts to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// NotifierConfig describes a notification channel in the rules file.
+// Exactly one of Webhook, SMTP and File must be set.
+type NotifierConfig struct {
+	Name    string         `yaml:"name"`
+	Webhook *WebhookConfig `yaml:"webhook"`
+	SMTP    *SMTPConfig    `yaml:"smtp"`
+	File    *FileConfig    `yaml:"file"`
+	// Retries is the number of extra attempts after a failed delivery,
+	// DefaultRetries when omitted.
+	Retries *int `yaml:"retries"`
+	// RetryBackoff is the delay before the first retry, doubled for each next one.
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// Timeout limits a single delivery attempt, DefaultNotifyTimeout when omitted.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c *NotifierConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	var err error
+	kinds := 0
+	if c.Webhook != nil {
+		kinds++
+		err = c.Webhook.validate()
+	}
+	if c.SMTP != nil {
+		kinds++
+		err = c.SMTP.validate()
+	}
+	if c.File != nil {
+		kinds++
+		err = c.File.validate()
+	}
+	if kinds != 1 {
+		return errors.New("exactly one of webhook, smtp and file is required")
+	}
+	if err != nil {
+		return err
+	}
+	if c.Retries != nil && *c.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	if c.RetryBackoff < 0 || c.Timeout < 0 {
+		return errors.New("retry_backoff and timeout must not be negative")
+	}
+
+	return nil
+}
+
+// Channel is a named notifier with its delivery settings.
+// A zero Timeout leaves attempts unlimited.
+type Channel struct {
+	Name         string
+	Notifier     Notifier
+	Retries      int
+	RetryBackoff time.Duration
+	Timeout      time.Duration
+}
+
+// Channels builds the notification channels described by the config.
+func (c *Config) Channels() ([]Channel, error) {
+	channels := make([]Channel, 0, len(c.Notifiers))
+	for _, nc := range c.Notifiers {
+		ch := Channel{
+			Name:         nc.Name,
+			Retries:      DefaultRetries,
+			RetryBackoff: nc.RetryBackoff,
+			Timeout:      nc.Timeout,
+		}
+		if nc.Retries != nil {
+			ch.Retries = *nc.Retries
+		}
+		if ch.RetryBackoff == 0 {
+			ch.RetryBackoff = DefaultRetryBackoff
+		}
+		if ch.Timeout == 0 {
+			ch.Timeout = DefaultNotifyTimeout
+		}
+		switch {
+		case nc.Webhook != nil:
+			ch.Notifier = NewWebhookNotifier(*nc.Webhook)
+		case nc.SMTP != nil:
+			ch.Notifier = NewSMTPNotifier(*nc.SMTP)
+		case nc.File != nil:
+			ch.Notifier = NewFileNotifier(nc.File.Path)
+		default:
+			return nil, fmt.Errorf("notifier %q: no channel configured", nc.Name)
+		}
+		channels = append(channels, ch)
+	}
+
+	return channels, nil
+}
+
+// sentState is what a channel was last told about an alert.
+type sentState struct {
+	state    State
+	activeAt time.Time
+	at       time.Time
+}
+
+// Dispatcher sends alert state changes to channels. Each channel hears about
+// an alert once when it fires, again every repeat interval while it keeps
+// firing, and once when it resolves; pending alerts are not sent, and
+// neither are silenced or inhibited ones.
+// A failed delivery is retried, and if all attempts fail the same alerts are
+// offered again on the next round.
+type Dispatcher struct {
+	mu       sync.Mutex
+	channels []Channel
+	rules    map[string]*Rule
+	sent     map[string]sentState
+	onError  func(error)
+}
+
+func NewDispatcher(rules []Rule, channels []Channel) *Dispatcher {
+	byName := make(map[string]*Rule, len(rules))
+	for i := range rules {
+		byName[rules[i].Name] = &rules[i]
+	}
+
+	return &Dispatcher{
+		channels: channels,
+		rules:    byName,
+		sent:     make(map[string]sentState),
+	}
+}
+
+// SetErrorHandler sets a callback for deliveries that failed after all retries.
+func (d *Dispatcher) SetErrorHandler(onError func(error)) {
+	d.onError = onError
+}
+
+// Dispatch sends the alerts each channel has not been told about yet and
+// waits for the deliveries. alerts is the full current list of the Evaluator.
+func (d *Dispatcher) Dispatch(ctx context.Context, alerts []Alert, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	batches := make([][]Alert, len(d.channels))
+	for i, ch := range d.channels {
+		for _, a := range alerts {
+			if d.due(ch.Name, a, now) {
+				batches[i] = append(batches[i], a)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(d.channels))
+	for i := range d.channels {
+		if len(batches[i]) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = d.deliver(ctx, d.channels[i], batches[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, ch := range d.channels {
+		if errs[i] != nil {
+			if d.onError != nil {
+				d.onError(fmt.Errorf("notifier %q: %w", ch.Name, errs[i]))
+			}
+
+			continue
+		}
+		for _, a := range batches[i] {
+			d.sent[sentKey(ch.Name, a)] = sentState{state: a.State, activeAt: a.ActiveAt, at: now}
+		}
+	}
+
+	// Forget alerts the Evaluator no longer lists
+	current := make(map[string]bool, len(alerts)*len(d.channels))
+	for _, ch := range d.channels {
+		for _, a := range alerts {
+			current[sentKey(ch.Name, a)] = true
+		}
+	}
+	for key := range d.sent {
+		if !current[key] {
+			delete(d.sent, key)
+		}
+	}
+}
+
+// due reports whether alert a has to be sent to channel name.
+func (d *Dispatcher) due(name string, a Alert, now time.Time) bool {
+	rule, ok := d.rules[a.Rule]
+	if !ok || !routed(rule, name) || a.Suppressed() {
+		return false
+	}
+	last, ok := d.sent[sentKey(name, a)]
+	// A new activation of the same rule and series starts over
+	if ok && !last.activeAt.Equal(a.ActiveAt) {
+		ok = false
+	}
+
+	switch a.State {
+	case StateFiring:
+		return !ok || last.state != StateFiring || now.Sub(last.at) >= rule.repeatInterval()
+	case StateResolved:
+		// Only alerts the channel saw firing are worth resolving
+		return ok && last.state == StateFiring
+	}
+
+	return false
+}
+
+// deliver sends alerts to ch, retrying with exponential backoff.
+func (d *Dispatcher) deliver(ctx context.Context, ch Channel, alerts []Alert) error {
+	backoff := ch.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if ch.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, ch.Timeout)
+		}
+		err = ch.Notifier.Notify(attemptCtx, alerts)
+		cancel()
+		if err == nil || attempt >= ch.Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return err
+}
+
+func routed(rule *Rule, channel string) bool {
+	return len(rule.Notify) == 0 || slices.Contains(rule.Notify, channel)
+}
+
+func sentKey(channel string, a Alert) string {
+	return channel + "\x00" + a.Rule + "\x00" + a.Series
+}
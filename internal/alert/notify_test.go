@@ -0,0 +1,210 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeNotifier struct {
+	mu      sync.Mutex
+	batches [][]Alert
+	fail    int
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, alerts []Alert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail > 0 {
+		f.fail--
+
+		return errors.New("unavailable")
+	}
+	f.batches = append(f.batches, alerts)
+
+	return nil
+}
+
+func (f *fakeNotifier) sent() [][]Alert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := f.batches
+	f.batches = nil
+
+	return out
+}
+
+func TestDispatcher_DedupRepeatResolve(t *testing.T) {
+	src := &fakeSource{gauges: map[string]float64{"temp": 90}}
+	rules := []Rule{{Name: "Hot", Metric: "temp", Type: "gauge", Op: ">", Threshold: 80, For: time.Minute, RepeatInterval: time.Hour}}
+	ops, audit := &fakeNotifier{}, &fakeNotifier{}
+	e := NewEvaluator(rules, src)
+	d := NewDispatcher(rules, []Channel{{Name: "ops", Notifier: ops}, {Name: "audit", Notifier: audit}})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	round := func(at time.Duration) {
+		e.Evaluate(start.Add(at))
+		d.Dispatch(context.Background(), e.Alerts(), start.Add(at))
+	}
+
+	// Pending alerts are not sent
+	round(0)
+	if got := ops.sent(); len(got) != 0 {
+		t.Fatalf("pending alert must not be sent, got %+v", got)
+	}
+
+	round(time.Minute)
+	got := ops.sent()
+	if len(got) != 1 || len(got[0]) != 1 || got[0][0].State != StateFiring {
+		t.Fatalf("expected one firing notification, got %+v", got)
+	}
+	if len(audit.sent()) != 1 {
+		t.Fatalf("every channel must be notified")
+	}
+
+	// Still firing: deduplicated until the repeat interval passes
+	round(30 * time.Minute)
+	if got := ops.sent(); len(got) != 0 {
+		t.Fatalf("expected no repeat yet, got %+v", got)
+	}
+	round(time.Minute + time.Hour)
+	if got := ops.sent(); len(got) != 1 || got[0][0].State != StateFiring {
+		t.Fatalf("expected repeated notification, got %+v", got)
+	}
+
+	src.gauges["temp"] = 50
+	round(2 * time.Hour)
+	if got := ops.sent(); len(got) != 1 || got[0][0].State != StateResolved {
+		t.Fatalf("expected resolved notification, got %+v", got)
+	}
+	round(2*time.Hour + time.Minute)
+	if got := ops.sent(); len(got) != 0 {
+		t.Fatalf("resolved must be sent once, got %+v", got)
+	}
+}
+
+func TestDispatcher_RetriesAndRouting(t *testing.T) {
+	rules := []Rule{{Name: "Hot", Notify: []string{"ops"}}}
+	alerts := []Alert{{Rule: "Hot", Series: "temp", State: StateFiring}}
+	ops := &fakeNotifier{fail: 2}
+	other := &fakeNotifier{}
+	d := NewDispatcher(rules, []Channel{
+		{Name: "ops", Notifier: ops, Retries: 2, RetryBackoff: time.Millisecond},
+		{Name: "other", Notifier: other},
+	})
+	var errs []error
+	d.SetErrorHandler(func(err error) { errs = append(errs, err) })
+
+	d.Dispatch(context.Background(), alerts, time.Now())
+	if len(ops.sent()) != 1 || len(errs) != 0 {
+		t.Fatalf("expected delivery after retries, errors: %v", errs)
+	}
+	if len(other.sent()) != 0 {
+		t.Fatalf("rule must only notify its channels")
+	}
+
+	// Silenced alerts are not sent
+	muted := []Alert{{Rule: "Hot", Series: "temp3", State: StateFiring, SilencedBy: "abc"}}
+	d.Dispatch(context.Background(), muted, time.Now())
+	if len(ops.sent()) != 0 {
+		t.Fatalf("silenced alert must not be sent")
+	}
+
+	// Failed deliveries are reported and offered again on the next round
+	alerts[0].Series = "temp2"
+	ops.fail = 3
+	d.Dispatch(context.Background(), alerts, time.Now())
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `notifier "ops"`) {
+		t.Fatalf("expected one delivery error, got %v", errs)
+	}
+	d.Dispatch(context.Background(), alerts, time.Now())
+	if len(ops.sent()) != 1 {
+		t.Fatalf("expected redelivery on the next round")
+	}
+}
+
+func TestFileNotifier_AppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.jsonl")
+	n := NewFileNotifier(path)
+	for _, state := range []State{StateFiring, StateResolved} {
+		if err := n.Notify(context.Background(), []Alert{{Rule: "Hot", Series: "temp", State: state, Value: 90}}); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var states []State
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec fileRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		if rec.NotifiedAt.IsZero() || rec.Rule != "Hot" || rec.Value != 90 {
+			t.Fatalf("unexpected record: %+v", rec)
+		}
+		states = append(states, rec.State)
+	}
+	if len(states) != 2 || states[0] != StateFiring || states[1] != StateResolved {
+		t.Fatalf("unexpected states: %v", states)
+	}
+}
+
+func TestParseConfig_Notifiers(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+notifiers:
+  - name: ops
+    webhook:
+      url: https://hooks.example.com/alerts
+      headers: {Authorization: Bearer x}
+    retries: 0
+  - name: mail
+    smtp: {addr: "localhost:25", from: alerts@example.com, to: [ops@example.com]}
+    timeout: 5s
+  - name: log
+    file: {path: /tmp/alerts.jsonl}
+rules:
+  - {name: Hot, metric: temp, op: ">", threshold: 80, notify: [ops, log], repeat_interval: 30m}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if cfg.Rules[0].RepeatInterval != 30*time.Minute || len(cfg.Rules[0].Notify) != 2 {
+		t.Fatalf("unexpected rule: %+v", cfg.Rules[0])
+	}
+	channels, err := cfg.Channels()
+	if err != nil {
+		t.Fatalf("channels: %v", err)
+	}
+	if len(channels) != 3 || channels[0].Retries != 0 || channels[1].Retries != DefaultRetries ||
+		channels[1].Timeout != 5*time.Second || channels[2].Timeout != DefaultNotifyTimeout {
+		t.Fatalf("unexpected channels: %+v", channels)
+	}
+
+	for name, tc := range map[string]struct {
+		cfg string
+		err string
+	}{
+		"two kinds":        {`notifiers: [{name: n, file: {path: a}, webhook: {url: "http://x"}}]`, "exactly one"},
+		"bad url":          {`notifiers: [{name: n, webhook: {url: "ftp://x"}}]`, "invalid webhook url"},
+		"no recipients":    {`notifiers: [{name: n, smtp: {addr: "h:25", from: a@b}}]`, "from and to"},
+		"duplicate":        {`notifiers: [{name: n, file: {path: a}}, {name: n, file: {path: b}}]`, "duplicate name"},
+		"unknown notifier": {`rules: [{name: r, metric: a, op: ">", threshold: 1, notify: [x]}]`, "unknown notifier"},
+		"unknown source":   {`inhibit_rules: [{source: x, equal: [host]}]`, "unknown source rule"},
+	} {
+		_, err := ParseConfig([]byte(tc.cfg))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error containing %q, got %v", name, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package alert evaluates threshold rules against stored metrics.
+package alert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule fires when a series of Metric compares to Threshold with Op
+// continuously for at least For.
+type Rule struct {
+	Name string `yaml:"name" json:"name"`
+	// Metric is the metric name; every series of it is evaluated separately.
+	Metric string `yaml:"metric" json:"metric"`
+	// Type is gauge or counter, gauge when omitted.
+	Type string `yaml:"type" json:"type"`
+	// Labels restrict the rule to series carrying all of them.
+	Labels    map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Op        string            `yaml:"op" json:"op"`
+	Threshold float64           `yaml:"threshold" json:"threshold"`
+	For       time.Duration     `yaml:"for" json:"for"`
+	Severity  string            `yaml:"severity" json:"severity,omitempty"`
+	Summary   string            `yaml:"summary" json:"summary,omitempty"`
+	// Notify lists the notifiers of the rule; all notifiers when empty.
+	Notify []string `yaml:"notify" json:"notify,omitempty"`
+	// RepeatInterval is how often a still firing alert is sent again,
+	// DefaultRepeatInterval when zero.
+	RepeatInterval time.Duration `yaml:"repeat_interval" json:"repeat_interval,omitempty"`
+}
+
+// Config is the layout of the rules file.
+type Config struct {
+	Rules        []Rule           `yaml:"rules"`
+	Notifiers    []NotifierConfig `yaml:"notifiers"`
+	InhibitRules []InhibitRule    `yaml:"inhibit_rules"`
+}
+
+// LoadConfig reads a YAML or JSON file with a top-level "rules" list and
+// optional "notifiers" and "inhibit_rules" lists. Durations are written like "30s" or "5m".
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(data)
+}
+
+// ParseConfig parses and validates the contents of a rules file.
+// JSON is a subset of YAML, so both go through the YAML decoder.
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse rules: %w", err)
+	}
+
+	notifiers := make(map[string]bool, len(cfg.Notifiers))
+	for i := range cfg.Notifiers {
+		n := &cfg.Notifiers[i]
+		if err := n.validate(); err != nil {
+			return nil, fmt.Errorf("notifier #%d %q: %w", i, n.Name, err)
+		}
+		if notifiers[n.Name] {
+			return nil, fmt.Errorf("notifier #%d %q: duplicate name", i, n.Name)
+		}
+		notifiers[n.Name] = true
+	}
+
+	seen := make(map[string]bool, len(cfg.Rules))
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Type == "" {
+			r.Type = models.Gauge
+		}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("rule #%d %q: %w", i, r.Name, err)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule #%d %q: duplicate name", i, r.Name)
+		}
+		seen[r.Name] = true
+		for _, name := range r.Notify {
+			if !notifiers[name] {
+				return nil, fmt.Errorf("rule #%d %q: unknown notifier %q", i, r.Name, name)
+			}
+		}
+	}
+
+	for i := range cfg.InhibitRules {
+		if err := cfg.InhibitRules[i].validate(seen); err != nil {
+			return nil, fmt.Errorf("inhibit rule #%d: %w", i, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Metric == "" {
+		return errors.New("metric is required")
+	}
+	if r.Type != models.Gauge && r.Type != models.Counter {
+		return fmt.Errorf("unsupported type %q", r.Type)
+	}
+	for name := range r.Labels {
+		if !models.ValidLabelName(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if _, ok := comparators[r.Op]; !ok {
+		return fmt.Errorf("unsupported op %q", r.Op)
+	}
+	if math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+		return errors.New("threshold must be finite")
+	}
+	if r.For < 0 {
+		return errors.New("for must not be negative")
+	}
+	if r.RepeatInterval < 0 {
+		return errors.New("repeat_interval must not be negative")
+	}
+
+	return nil
+}
+
+// repeatInterval returns the effective repeat interval.
+func (r *Rule) repeatInterval() time.Duration {
+	if r.RepeatInterval == 0 {
+		return DefaultRepeatInterval
+	}
+
+	return r.RepeatInterval
+}
+
+// matches reports whether v satisfies the rule condition.
+func (r *Rule) matches(v float64) bool {
+	return comparators[r.Op](v, r.Threshold)
+}
+
+var comparators = map[string]func(v, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
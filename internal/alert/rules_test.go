@@ -0,0 +1,89 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_YAMLAndJSON(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "rules.yaml")
+	yamlRules := `
+rules:
+  - name: HighHeap
+    metric: HeapAlloc
+    labels:
+      host: web01
+    op: ">"
+    threshold: 1e9
+    for: 1m
+    severity: warning
+  - name: PollStalled
+    metric: PollCount
+    type: counter
+    op: "<"
+    threshold: 1
+`
+	if err := os.WriteFile(yamlPath, []byte(yamlRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(yamlPath)
+	if err != nil {
+		t.Fatalf("load yaml: %v", err)
+	}
+	rules := cfg.Rules
+	if len(rules) != 2 || rules[0].For != time.Minute || rules[0].Labels["host"] != "web01" || rules[0].Threshold != 1e9 {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	if rules[1].Type != "counter" || rules[1].For != 0 {
+		t.Fatalf("unexpected second rule: %+v", rules[1])
+	}
+
+	jsonPath := filepath.Join(dir, "rules.json")
+	jsonRules := `{"rules": [{"name": "Hot", "metric": "temp", "op": ">=", "threshold": 80, "for": "30s"}]}`
+	if err := os.WriteFile(jsonPath, []byte(jsonRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig(jsonPath)
+	if err != nil {
+		t.Fatalf("load json: %v", err)
+	}
+	rules = cfg.Rules
+	if len(rules) != 1 || rules[0].Type != "gauge" || rules[0].For != 30*time.Second {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+}
+
+func TestParseConfig_Validation(t *testing.T) {
+	for name, tc := range map[string]struct {
+		rules string
+		err   string
+	}{
+		"no name":       {`rules: [{metric: a, op: ">", threshold: 1}]`, "name is required"},
+		"no metric":     {`rules: [{name: r, op: ">", threshold: 1}]`, "metric is required"},
+		"bad op":        {`rules: [{name: r, metric: a, op: "=>", threshold: 1}]`, "unsupported op"},
+		"bad type":      {`rules: [{name: r, metric: a, type: histogram, op: ">", threshold: 1}]`, "unsupported type"},
+		"negative for":  {`rules: [{name: r, metric: a, op: ">", threshold: 1, for: -1s}]`, "must not be negative"},
+		"infinite":      {`rules: [{name: r, metric: a, op: ">", threshold: .inf}]`, "must be finite"},
+		"bad label":     {`rules: [{name: r, metric: a, op: ">", threshold: 1, labels: {"a-b": x}}]`, "invalid label name"},
+		"unknown field": {`rules: [{name: r, metric: a, op: ">", threshold: 1, treshold: 2}]`, "treshold"},
+		"duplicate": {
+			`rules: [{name: r, metric: a, op: ">", threshold: 1}, {name: r, metric: b, op: ">", threshold: 1}]`,
+			"duplicate name",
+		},
+	} {
+		_, err := ParseConfig([]byte(tc.rules))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error containing %q, got %v", name, tc.err, err)
+		}
+	}
+
+	// An empty file has no rules
+	cfg, err := ParseConfig(nil)
+	if err != nil || len(cfg.Rules) != 0 {
+		t.Fatalf("empty file: %v, %v", cfg, err)
+	}
+}
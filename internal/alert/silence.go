@@ -0,0 +1,230 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+var (
+	// ErrSilenceNotFound is returned when deleting an unknown or expired silence.
+	ErrSilenceNotFound = errors.New("not found")
+	// ErrBadSilence wraps validation errors of a new silence.
+	ErrBadSilence = errors.New("bad value")
+)
+
+// Silence mutes the alerts of matching series until EndsAt. Silenced alerts
+// are still evaluated and listed, but not sent to notifiers.
+type Silence struct {
+	ID string `json:"id"`
+	// Metric is a metric name pattern in path.Match syntax, e.g. "Heap*".
+	Metric string `json:"metric"`
+	// Labels restrict the silence to series carrying all of them.
+	Labels    map[string]string `json:"labels,omitempty"`
+	EndsAt    time.Time         `json:"ends_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	CreatedBy string            `json:"created_by,omitempty"`
+	Comment   string            `json:"comment,omitempty"`
+}
+
+func (s *Silence) validate(now time.Time) error {
+	if s.Metric == "" {
+		return errors.New("metric is required")
+	}
+	if _, err := path.Match(s.Metric, ""); err != nil {
+		return fmt.Errorf("invalid metric pattern %q", s.Metric)
+	}
+	for name := range s.Labels {
+		if !models.ValidLabelName(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if !s.EndsAt.After(now) {
+		return errors.New("ends_at must be in the future")
+	}
+
+	return nil
+}
+
+func (s *Silence) matches(a *Alert, now time.Time) bool {
+	if !now.Before(s.EndsAt) || !hasLabels(a.Labels, s.Labels) {
+		return false
+	}
+	ok, _ := path.Match(s.Metric, a.Metric)
+
+	return ok
+}
+
+// Silences keeps the silences and, when created by LoadSilences, saves them
+// to a file after every change.
+type Silences struct {
+	mu    sync.RWMutex
+	path  string
+	items map[string]Silence
+}
+
+func NewSilences() *Silences {
+	return &Silences{items: make(map[string]Silence)}
+}
+
+// SilencesPath returns the silences file kept next to a state snapshot:
+// /data/metrics.json becomes /data/metrics.silences.json.
+func SilencesPath(snapshotPath string) string {
+	ext := filepath.Ext(snapshotPath)
+	if ext == "" {
+		ext = ".json"
+	}
+
+	return strings.TrimSuffix(snapshotPath, filepath.Ext(snapshotPath)) + ".silences" + ext
+}
+
+// LoadSilences reads the silences saved at path; a missing file means none.
+// Expired silences are dropped.
+func LoadSilences(path string, now time.Time) (*Silences, error) {
+	s := NewSilences()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Silence
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("parse silences: %w", err)
+	}
+	for _, item := range items {
+		if item.ID == "" {
+			return nil, errors.New("parse silences: silence without id")
+		}
+		if now.Before(item.EndsAt) {
+			s.items[item.ID] = item
+		}
+	}
+
+	return s, nil
+}
+
+// Add validates and stores a new silence, assigning its ID and CreatedAt.
+func (s *Silences) Add(sl Silence, now time.Time) (Silence, error) {
+	if err := sl.validate(now); err != nil {
+		return Silence{}, fmt.Errorf("%w: %v", ErrBadSilence, err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Silence{}, err
+	}
+	sl.ID = hex.EncodeToString(id)
+	sl.CreatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[sl.ID] = sl
+	if err := s.save(now); err != nil {
+		delete(s.items, sl.ID)
+
+		return Silence{}, err
+	}
+
+	return sl, nil
+}
+
+// Delete removes a silence before it expires.
+func (s *Silences) Delete(id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sl, ok := s.items[id]
+	if !ok || !now.Before(sl.EndsAt) {
+		return ErrSilenceNotFound
+	}
+	delete(s.items, id)
+	if err := s.save(now); err != nil {
+		s.items[id] = sl
+
+		return err
+	}
+
+	return nil
+}
+
+// List returns the active silences ordered by expiry.
+func (s *Silences) List(now time.Time) []Silence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Silence, 0, len(s.items))
+	for _, sl := range s.items {
+		if now.Before(sl.EndsAt) {
+			out = append(out, sl)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].EndsAt.Equal(out[j].EndsAt) {
+			return out[i].EndsAt.Before(out[j].EndsAt)
+		}
+
+		return out[i].ID < out[j].ID
+	})
+
+	return out
+}
+
+// Match returns the ID of an active silence matching a, or "".
+func (s *Silences) Match(a *Alert, now time.Time) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id := ""
+	for _, sl := range s.items {
+		// The smallest ID keeps the answer stable when several silences match
+		if sl.matches(a, now) && (id == "" || sl.ID < id) {
+			id = sl.ID
+		}
+	}
+
+	return id
+}
+
+// save writes the active silences and forgets expired ones. Called with mu held.
+func (s *Silences) save(now time.Time) error {
+	for id, sl := range s.items {
+		if !now.Before(sl.EndsAt) {
+			delete(s.items, id)
+		}
+	}
+	if s.path == "" {
+		return nil
+	}
+
+	items := make([]Silence, 0, len(s.items))
+	for _, sl := range s.items {
+		items = append(items, sl)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,89 @@
+package alert
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSilences_MatchAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.silences.json")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := LoadSilences(path, now)
+	if err != nil {
+		t.Fatalf("load missing file: %v", err)
+	}
+
+	sl, err := s.Add(Silence{Metric: "Heap*", Labels: map[string]string{"host": "web01"}, EndsAt: now.Add(time.Hour), Comment: "deploy"}, now)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if sl.ID == "" || !sl.CreatedAt.Equal(now) {
+		t.Fatalf("expected id and creation time, got %+v", sl)
+	}
+
+	heap := &Alert{Metric: "HeapAlloc", Labels: map[string]string{"host": "web01"}}
+	if got := s.Match(heap, now); got != sl.ID {
+		t.Fatalf("expected match by %s, got %q", sl.ID, got)
+	}
+	for name, a := range map[string]*Alert{
+		"other host":   {Metric: "HeapAlloc", Labels: map[string]string{"host": "web02"}},
+		"other metric": {Metric: "Alloc", Labels: map[string]string{"host": "web01"}},
+	} {
+		if got := s.Match(a, now); got != "" {
+			t.Fatalf("%s: unexpected match %q", name, got)
+		}
+	}
+	if got := s.Match(heap, now.Add(time.Hour)); got != "" {
+		t.Fatalf("expired silence must not match")
+	}
+
+	// Survives a restart, but not its expiry
+	restored, err := LoadSilences(path, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if list := restored.List(now.Add(time.Minute)); len(list) != 1 || list[0].ID != sl.ID || list[0].Comment != "deploy" {
+		t.Fatalf("unexpected restored silences: %+v", list)
+	}
+	if expired, _ := LoadSilences(path, now.Add(2*time.Hour)); len(expired.List(now.Add(2*time.Hour))) != 0 {
+		t.Fatalf("expired silences must be dropped on load")
+	}
+
+	if err := restored.Delete(sl.ID, now); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := restored.Delete(sl.ID, now); !errors.Is(err, ErrSilenceNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if again, _ := LoadSilences(path, now); len(again.List(now)) != 0 {
+		t.Fatalf("deletion must be persisted")
+	}
+}
+
+func TestSilences_Validation(t *testing.T) {
+	now := time.Now()
+	s := NewSilences()
+	for name, sl := range map[string]Silence{
+		"no metric":   {EndsAt: now.Add(time.Hour)},
+		"bad pattern": {Metric: "Heap[", EndsAt: now.Add(time.Hour)},
+		"bad label":   {Metric: "a", Labels: map[string]string{"a-b": "x"}, EndsAt: now.Add(time.Hour)},
+		"past end":    {Metric: "a", EndsAt: now.Add(-time.Second)},
+	} {
+		if _, err := s.Add(sl, now); !errors.Is(err, ErrBadSilence) {
+			t.Fatalf("%s: expected bad silence error, got %v", name, err)
+		}
+	}
+}
+
+func TestSilencesPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"/tmp/metrics-db.json": "/tmp/metrics-db.silences.json",
+		"/data/state":          "/data/state.silences.json",
+	} {
+		if got := SilencesPath(in); got != want {
+			t.Fatalf("SilencesPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SMTPConfig configures email delivery. STARTTLS is used when the server
+// offers it; credentials are only sent over TLS or to localhost.
+type SMTPConfig struct {
+	// Addr is host:port of the SMTP server.
+	Addr     string   `yaml:"addr"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+}
+
+func (c *SMTPConfig) validate() error {
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("invalid smtp addr %q", c.Addr)
+	}
+	if c.From == "" || len(c.To) == 0 {
+		return errors.New("smtp from and to are required")
+	}
+	for _, addr := range append([]string{c.From}, c.To...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return fmt.Errorf("invalid email address %q", addr)
+		}
+	}
+
+	return nil
+}
+
+// SMTPNotifier sends one plain text email per batch.
+type SMTPNotifier struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPNotifier(cfg SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg}
+}
+
+// Notify delivers the email; ctx bounds the whole SMTP session.
+func (n *SMTPNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	host, _, _ := net.SplitHostPort(n.cfg.Addr)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range n.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(alerts, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message renders the email: the subject counts the alerts by state,
+// the body has a line per alert.
+func (n *SMTPNotifier) message(alerts []Alert, now time.Time) []byte {
+	counts := make(map[State]int)
+	for _, a := range alerts {
+		counts[a.State]++
+	}
+	parts := make([]string, 0, len(counts))
+	for state, count := range counts {
+		parts = append(parts, fmt.Sprintf("%d %s", count, state))
+	}
+	sort.Strings(parts)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: [alerts] %s\r\n", strings.Join(parts, ", "))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&b, "[%s] %s: %s = %v (%s %v)",
+			strings.ToUpper(string(a.State)), a.Rule, a.Series, a.Value, a.Op, a.Threshold)
+		if a.Severity != "" {
+			fmt.Fprintf(&b, " severity=%s", a.Severity)
+		}
+		b.WriteString("\r\n")
+		if a.Summary != "" {
+			fmt.Fprintf(&b, "    %s\r\n", a.Summary)
+		}
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,95 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts a single session and returns the received DATA.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				data <- b.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), data
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	n := NewSMTPNotifier(SMTPConfig{Addr: addr, From: "alerts@example.com", To: []string{"ops@example.com"}})
+	alerts := []Alert{
+		{Rule: "Hot", Series: `temp{host="web01"}`, State: StateFiring, Value: 90, Op: ">", Threshold: 80, Summary: "too hot"},
+		{Rule: "Cold", Series: "temp", State: StateResolved, Value: 20, Op: "<", Threshold: 10},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, alerts); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	msg := <-data
+	for _, must := range []string{
+		"To: ops@example.com",
+		"Subject: [alerts] 1 firing, 1 resolved",
+		`[FIRING] Hot: temp{host="web01"} = 90 (> 80)`,
+		"    too hot",
+		"[RESOLVED] Cold: temp = 20 (< 10)",
+	} {
+		if !strings.Contains(msg, must) {
+			t.Fatalf("message missing %q:\n%s", must, msg)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var calls int
+	var got struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(WebhookConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	alerts := []Alert{{Rule: "Hot", Series: "temp", State: StateFiring, Value: 90}}
+	if err := n.Notify(context.Background(), alerts); err == nil {
+		t.Fatalf("expected error for 502 response")
+	}
+
+	// The dispatcher retries the failed request
+	d := NewDispatcher([]Rule{{Name: "Hot"}}, []Channel{{Name: "hook", Notifier: n, Retries: 1, RetryBackoff: time.Millisecond}})
+	calls = 0
+	d.Dispatch(context.Background(), alerts, time.Now())
+	if calls != 2 || len(got.Alerts) != 1 || got.Alerts[0].Rule != "Hot" || got.Alerts[0].Value != 90 {
+		t.Fatalf("unexpected delivery: calls=%d body=%+v", calls, got)
+	}
+}
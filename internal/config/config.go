@@ -3,16 +3,31 @@ package config
 import (
 	"flag"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	reportSecDefault       = 10
-	pollSecDefault         = 2
-	storeIntervaleDefault  = 300
-	FileStoragePathDefault = "/tmp/metrics-db.json"
+	reportSecDefault        = 10
+	pollSecDefault          = 2
+	storeIntervaleDefault   = 300
+	FileStoragePathDefault  = "/tmp/metrics-db.json"
+	retryDelaysDefault      = "1s,3s,5s"
+	rateLimitDefault        = 1
+	summaryMaxAgeDefault    = 10 * time.Minute
+	setPrecisionDefault     = 14
+	historyRetentionDefault = time.Hour
+	historySamplesDefault   = 3600
+	historyEngineDefault    = "ring"
+	alertIntervalDefault    = 15 * time.Second
+	anomalyAlphaDefault     = 0.1
+	anomalyKDefault         = 3.0
+	snapshotCompressDefault = "none"
+	snapshotGenerationEvery = time.Minute
 )
 
 // ServerConfig holds configuration for the HTTP server.
@@ -22,6 +37,51 @@ type ServerConfig struct {
 	StoreIntervale  time.Duration
 	FileStoragePath string
 	Restore         bool
+	// SnapshotCompression is the body encoding of the state file: none, gzip or zstd.
+	SnapshotCompression string
+	// SnapshotRetention is the number of timestamped generations kept next to
+	// the state file; 0 keeps none.
+	SnapshotRetention int
+	// SnapshotGenerationInterval is the minimum time between two generations,
+	// so that synchronous saves (STORE_INTERVAL=0) do not keep one per write.
+	SnapshotGenerationInterval time.Duration
+	// Key is the shared secret for HMAC-SHA256 signing; empty disables signing.
+	Key string
+	// DatabaseDSN is the PostgreSQL connection string; when set it replaces the file store.
+	DatabaseDSN string
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile, when set, requires client certificates signed by this CA.
+	TLSClientCAFile string
+	// CryptoKey is the path to the RSA private key PEM used to decrypt agent payloads.
+	CryptoKey string
+	// TrustedSubnet is the CIDR allowed to write metrics; empty allows everyone.
+	TrustedSubnet string
+	// HistogramBuckets are the bounds of histograms created without declared
+	// buckets; empty keeps the built-in defaults.
+	HistogramBuckets []float64
+	// SummaryMaxAge is the sliding window over which summary quantiles are computed.
+	SummaryMaxAge time.Duration
+	// SetPrecision is the HyperLogLog precision of sets, 4..18; the error is about 1.04/sqrt(2^p).
+	SetPrecision int
+	// HistoryRetention is how long samples are kept for range queries; 0 disables history.
+	HistoryRetention time.Duration
+	// HistorySamples caps the number of samples kept per series.
+	HistorySamples int
+	// HistoryEngine selects the history store: "ring" keeps raw samples,
+	// "gorilla" compresses them into chunks.
+	HistoryEngine string
+	// AlertRulesFile is a YAML or JSON file of alert rules and notifiers; empty disables alerting.
+	AlertRulesFile string
+	// AlertInterval is how often alert rules are evaluated.
+	AlertInterval time.Duration
+	// AnomalyMetrics are the gauges watched by the anomaly detector; empty disables it.
+	AnomalyMetrics []string
+	// AnomalyAlpha is the EWMA smoothing factor of the detector, (0, 1].
+	AnomalyAlpha float64
+	// AnomalyK is the band width of the detector in standard deviations.
+	AnomalyK float64
 }
 
 // AgentConfig holds configuration for the metrics agent.
@@ -30,10 +90,45 @@ type AgentConfig struct {
 	Address        string
 	ReportInterval time.Duration
 	PollInterval   time.Duration
+	// Key is the shared secret for HMAC-SHA256 signing; empty disables signing.
+	Key string
+	// RetryDelays are the pauses between attempts of a failed report; empty disables retries.
+	RetryDelays []time.Duration
+	// RateLimit is the number of concurrent outbound requests.
+	RateLimit int
+	// UseTLS switches reporting to HTTPS; it is implied by any TLS file option.
+	UseTLS bool
+	// TLSCAFile is a PEM bundle of CAs trusted instead of the system roots.
+	TLSCAFile string
+	// TLSCertFile and TLSKeyFile are the client certificate for mutual TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// CryptoKey is the path to the server RSA public key PEM used to encrypt payloads.
+	CryptoKey string
 }
 
 // LoadServerConfigFromFlags parses CLI flags for the server binary.
 // -a=<value> — listen address (default: localhost:8080).
+// -k=<value> — key for HMAC-SHA256 signing (default: empty, disabled).
+// -snapshot-compression=<value> — state file compression, none, gzip or zstd (default: none).
+// -snapshot-retention=<value> — timestamped generations of the state file to keep (default: 0, none).
+// -snapshot-generation-interval=<value> — minimum time between two generations (default: 1m).
+// -d=<value> — PostgreSQL DSN (default: empty, file storage is used).
+// -tls-cert, -tls-key=<path> — server certificate and key, enable HTTPS.
+// -tls-client-ca=<path> — CA bundle for client certificates, enables mutual TLS.
+// -crypto-key=<path> — RSA private key for decrypting agent payloads.
+// -t=<value> — CIDR of agents allowed to write metrics (default: empty, everyone).
+// -histogram-buckets=<value> — default histogram bucket bounds, e.g. 0.1,0.5,1 (default: built-in).
+// -summary-max-age=<value> — sliding window of summary quantiles (default: 10m).
+// -set-precision=<value> — HyperLogLog precision of sets, 4..18 (default: 14).
+// -history-retention=<value> — how long metric history is kept, 0 disables (default: 1h).
+// -history-samples=<value> — max samples of history per series (default: 3600).
+// -history-engine=<value> — history store, ring or gorilla (default: ring).
+// -alert-rules=<path> — YAML or JSON file of alert rules and notifiers (default: empty, disabled).
+// -alert-interval=<value> — alert rule evaluation interval (default: 15s).
+// -anomaly-metrics=<value> — gauges watched for anomalies, e.g. HeapAlloc,Alloc (default: empty, disabled).
+// -anomaly-alpha=<value> — EWMA smoothing factor of anomaly detection (default: 0.1).
+// -anomaly-k=<value> — anomaly band width in standard deviations (default: 3).
 func LoadServerConfigFromFlags() (*ServerConfig, error) {
 	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
@@ -41,11 +136,38 @@ func LoadServerConfigFromFlags() (*ServerConfig, error) {
 	cfg := &ServerConfig{}
 
 	var storeSec int
+	var histogramBuckets string
+	var anomalyMetrics string
 
 	fs.StringVar(&cfg.Address, "a", "localhost:8080", "HTTP server listen address")
 	fs.IntVar(&storeSec, "i", storeIntervaleDefault, "store interval in seconds")
 	fs.StringVar(&cfg.FileStoragePath, "f", FileStoragePathDefault, "full filename for storage file")
 	fs.BoolVar(&cfg.Restore, "r", true, "restore values on start")
+	fs.StringVar(&cfg.SnapshotCompression, "snapshot-compression", snapshotCompressDefault,
+		"state file compression: none, gzip or zstd")
+	fs.IntVar(&cfg.SnapshotRetention, "snapshot-retention", 0, "timestamped generations of the state file to keep")
+	fs.DurationVar(&cfg.SnapshotGenerationInterval, "snapshot-generation-interval", snapshotGenerationEvery,
+		"minimum time between two state file generations")
+	fs.StringVar(&cfg.Key, "k", "", "key for HMAC-SHA256 signing")
+	fs.StringVar(&cfg.DatabaseDSN, "d", "", "PostgreSQL connection string")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert", "", "path to PEM server certificate, enables HTTPS")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key", "", "path to PEM server private key")
+	fs.StringVar(&cfg.TLSClientCAFile, "tls-client-ca", "", "path to PEM CA bundle required for client certificates")
+	fs.StringVar(&cfg.CryptoKey, "crypto-key", "", "path to PEM RSA private key for payload decryption")
+	fs.StringVar(&cfg.TrustedSubnet, "t", "", "CIDR of agents allowed to write metrics")
+	fs.StringVar(&histogramBuckets, "histogram-buckets", "",
+		"comma-separated default histogram bucket bounds, empty for built-in")
+	fs.DurationVar(&cfg.SummaryMaxAge, "summary-max-age", summaryMaxAgeDefault, "sliding window of summary quantiles")
+	fs.IntVar(&cfg.SetPrecision, "set-precision", setPrecisionDefault, "HyperLogLog precision of sets, 4..18")
+	fs.DurationVar(&cfg.HistoryRetention, "history-retention", historyRetentionDefault,
+		"how long metric history is kept, 0 disables")
+	fs.IntVar(&cfg.HistorySamples, "history-samples", historySamplesDefault, "max samples of history per series")
+	fs.StringVar(&cfg.HistoryEngine, "history-engine", historyEngineDefault, "history store: ring or gorilla")
+	fs.StringVar(&cfg.AlertRulesFile, "alert-rules", "", "path to YAML or JSON alert rules file")
+	fs.DurationVar(&cfg.AlertInterval, "alert-interval", alertIntervalDefault, "alert rule evaluation interval")
+	fs.StringVar(&anomalyMetrics, "anomaly-metrics", "", "comma-separated gauges watched for anomalies")
+	fs.Float64Var(&cfg.AnomalyAlpha, "anomaly-alpha", anomalyAlphaDefault, "EWMA smoothing factor of anomaly detection")
+	fs.Float64Var(&cfg.AnomalyK, "anomaly-k", anomalyKDefault, "anomaly band width in standard deviations")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return nil, err
@@ -78,9 +200,165 @@ func LoadServerConfigFromFlags() (*ServerConfig, error) {
 			return nil, fmt.Errorf("invalid RESTORE, must be true or false: %q", restoreVal)
 		}
 	}
+	if v, ok := os.LookupEnv("SNAPSHOT_COMPRESSION"); ok && v != "" {
+		cfg.SnapshotCompression = v
+	}
+	switch cfg.SnapshotCompression {
+	case "none", "gzip", "zstd":
+	default:
+		return nil, fmt.Errorf("snapshot compression must be none, gzip or zstd, provided: %q", cfg.SnapshotCompression)
+	}
+	if v, ok := os.LookupEnv("SNAPSHOT_RETENTION"); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SNAPSHOT_RETENTION, must be an integer: %q", v)
+		}
+		cfg.SnapshotRetention = n
+	}
+	if cfg.SnapshotRetention < 0 {
+		return nil, fmt.Errorf("snapshot retention must not be negative, provided: %v", cfg.SnapshotRetention)
+	}
+	if v, ok := os.LookupEnv("SNAPSHOT_GENERATION_INTERVAL"); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SNAPSHOT_GENERATION_INTERVAL, must be a duration like 1m: %q", v)
+		}
+		cfg.SnapshotGenerationInterval = d
+	}
+	if cfg.SnapshotGenerationInterval < 0 {
+		return nil, fmt.Errorf("snapshot generation interval must not be negative, provided: %v", cfg.SnapshotGenerationInterval)
+	}
+	if key, ok := os.LookupEnv("KEY"); ok && key != "" {
+		cfg.Key = key
+	}
+	if dsn, ok := os.LookupEnv("DATABASE_DSN"); ok && dsn != "" {
+		cfg.DatabaseDSN = dsn
+	}
+	if v, ok := os.LookupEnv("TLS_CERT"); ok && v != "" {
+		cfg.TLSCertFile = v
+	}
+	if v, ok := os.LookupEnv("TLS_KEY"); ok && v != "" {
+		cfg.TLSKeyFile = v
+	}
+	if v, ok := os.LookupEnv("TLS_CLIENT_CA"); ok && v != "" {
+		cfg.TLSClientCAFile = v
+	}
+	if v, ok := os.LookupEnv("CRYPTO_KEY"); ok && v != "" {
+		cfg.CryptoKey = v
+	}
+	if v, ok := os.LookupEnv("TRUSTED_SUBNET"); ok && v != "" {
+		cfg.TrustedSubnet = v
+	}
+	if v, ok := os.LookupEnv("HISTOGRAM_BUCKETS"); ok && v != "" {
+		histogramBuckets = v
+	}
+	if v, ok := os.LookupEnv("SUMMARY_MAX_AGE"); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SUMMARY_MAX_AGE, must be a duration like 10m: %q", v)
+		}
+		cfg.SummaryMaxAge = d
+	}
+	if v, ok := os.LookupEnv("SET_PRECISION"); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SET_PRECISION, must be an integer: %q", v)
+		}
+		cfg.SetPrecision = n
+	}
+	if cfg.SetPrecision < 4 || cfg.SetPrecision > 18 {
+		return nil, fmt.Errorf("set precision must be between 4 and 18, provided: %v", cfg.SetPrecision)
+	}
+	if v, ok := os.LookupEnv("HISTORY_RETENTION"); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HISTORY_RETENTION, must be a duration like 1h: %q", v)
+		}
+		cfg.HistoryRetention = d
+	}
+	if v, ok := os.LookupEnv("HISTORY_SAMPLES"); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HISTORY_SAMPLES, must be an integer: %q", v)
+		}
+		cfg.HistorySamples = n
+	}
+	if v, ok := os.LookupEnv("HISTORY_ENGINE"); ok && v != "" {
+		cfg.HistoryEngine = v
+	}
+	if cfg.HistoryEngine != "ring" && cfg.HistoryEngine != "gorilla" {
+		return nil, fmt.Errorf("history engine must be ring or gorilla, provided: %q", cfg.HistoryEngine)
+	}
+	if v, ok := os.LookupEnv("ALERT_RULES"); ok && v != "" {
+		cfg.AlertRulesFile = v
+	}
+	if v, ok := os.LookupEnv("ALERT_INTERVAL"); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALERT_INTERVAL, must be a duration like 15s: %q", v)
+		}
+		cfg.AlertInterval = d
+	}
+	if cfg.AlertInterval <= 0 {
+		return nil, fmt.Errorf("alert interval must be positive, provided: %v", cfg.AlertInterval)
+	}
+	if v, ok := os.LookupEnv("ANOMALY_METRICS"); ok && v != "" {
+		anomalyMetrics = v
+	}
+	for _, name := range strings.Split(anomalyMetrics, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.AnomalyMetrics = append(cfg.AnomalyMetrics, name)
+		}
+	}
+	if v, ok := os.LookupEnv("ANOMALY_ALPHA"); ok && v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ANOMALY_ALPHA, must be a number: %q", v)
+		}
+		cfg.AnomalyAlpha = f
+	}
+	if v, ok := os.LookupEnv("ANOMALY_K"); ok && v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ANOMALY_K, must be a number: %q", v)
+		}
+		cfg.AnomalyK = f
+	}
+	if !(cfg.AnomalyAlpha > 0 && cfg.AnomalyAlpha <= 1) {
+		return nil, fmt.Errorf("anomaly alpha must be in (0, 1], provided: %v", cfg.AnomalyAlpha)
+	}
+	if !(cfg.AnomalyK > 0) || math.IsInf(cfg.AnomalyK, 0) {
+		return nil, fmt.Errorf("anomaly k must be positive, provided: %v", cfg.AnomalyK)
+	}
+	if cfg.HistoryRetention < 0 {
+		return nil, fmt.Errorf("history retention must not be negative, provided: %v", cfg.HistoryRetention)
+	}
+	if cfg.HistoryRetention > 0 && cfg.HistorySamples <= 0 {
+		return nil, fmt.Errorf("history samples must be greater then 0, provided: %v", cfg.HistorySamples)
+	}
+	if cfg.SummaryMaxAge <= 0 {
+		return nil, fmt.Errorf("summary max age must be positive, provided: %v", cfg.SummaryMaxAge)
+	}
+	if cfg.TrustedSubnet != "" {
+		if _, _, err := net.ParseCIDR(cfg.TrustedSubnet); err != nil {
+			return nil, fmt.Errorf("invalid trusted subnet %q: %w", cfg.TrustedSubnet, err)
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS certificate and key must be set together")
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("TLS client CA requires server certificate and key")
+	}
 
 	cfg.StoreIntervale = time.Duration(storeSec) * time.Second
 
+	buckets, err := parseBuckets(histogramBuckets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid histogram buckets %q: %w", histogramBuckets, err)
+	}
+	cfg.HistogramBuckets = buckets
+
 	return cfg, nil
 }
 
@@ -88,6 +366,13 @@ func LoadServerConfigFromFlags() (*ServerConfig, error) {
 // -a=<value> — server endpoint address (default: localhost:8080).
 // -r=<value> — report interval in seconds (default: 10).
 // -p=<value> — poll interval in seconds (default: 2).
+// -k=<value> — key for HMAC-SHA256 signing (default: empty, disabled).
+// -retry-delays=<value> — pauses between report retries (default: 1s,3s,5s).
+// -l=<value> — max number of concurrent outbound requests (default: 1).
+// -tls — report over HTTPS, implied by the TLS file options below.
+// -tls-ca=<path> — CA bundle trusted for the server certificate.
+// -tls-cert, -tls-key=<path> — client certificate and key for mutual TLS.
+// -crypto-key=<path> — server RSA public key for encrypting payloads.
 func LoadAgentConfigFromFlags() (*AgentConfig, error) {
 	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
@@ -96,10 +381,20 @@ func LoadAgentConfigFromFlags() (*AgentConfig, error) {
 
 	var reportSec int
 	var pollSec int
+	var retryDelays string
 
 	fs.StringVar(&cfg.Address, "a", "localhost:8080", "HTTP server endpoint address (host:port)")
 	fs.IntVar(&reportSec, "r", reportSecDefault, "report interval in seconds")
 	fs.IntVar(&pollSec, "p", pollSecDefault, "poll interval in seconds")
+	fs.StringVar(&cfg.Key, "k", "", "key for HMAC-SHA256 signing")
+	fs.StringVar(&retryDelays, "retry-delays", retryDelaysDefault,
+		"comma-separated pauses between report retries, empty to disable")
+	fs.IntVar(&cfg.RateLimit, "l", rateLimitDefault, "max number of concurrent outbound requests")
+	fs.BoolVar(&cfg.UseTLS, "tls", false, "report over HTTPS")
+	fs.StringVar(&cfg.TLSCAFile, "tls-ca", "", "path to PEM CA bundle trusted for the server certificate")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert", "", "path to PEM client certificate for mutual TLS")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key", "", "path to PEM client private key for mutual TLS")
+	fs.StringVar(&cfg.CryptoKey, "crypto-key", "", "path to PEM RSA public key of the server for payload encryption")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return nil, err
@@ -127,6 +422,37 @@ func LoadAgentConfigFromFlags() (*AgentConfig, error) {
 		}
 		pollSec = n
 	}
+	if key, ok := os.LookupEnv("KEY"); ok && key != "" {
+		cfg.Key = key
+	}
+	if v, ok := os.LookupEnv("RETRY_DELAYS"); ok {
+		retryDelays = v
+	}
+	if v, ok := os.LookupEnv("RATE_LIMIT"); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT, must be positive integer: %q", v)
+		}
+		cfg.RateLimit = n
+	}
+	if v, ok := os.LookupEnv("TLS_CA"); ok && v != "" {
+		cfg.TLSCAFile = v
+	}
+	if v, ok := os.LookupEnv("TLS_CERT"); ok && v != "" {
+		cfg.TLSCertFile = v
+	}
+	if v, ok := os.LookupEnv("TLS_KEY"); ok && v != "" {
+		cfg.TLSKeyFile = v
+	}
+	if v, ok := os.LookupEnv("CRYPTO_KEY"); ok && v != "" {
+		cfg.CryptoKey = v
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS client certificate and key must be set together")
+	}
+	if cfg.TLSCAFile != "" || cfg.TLSCertFile != "" {
+		cfg.UseTLS = true
+	}
 
 	if reportSec <= 0 {
 		return nil, fmt.Errorf("-r argument value must be greater then 0, provided: %v", reportSec)
@@ -134,8 +460,62 @@ func LoadAgentConfigFromFlags() (*AgentConfig, error) {
 	if pollSec <= 0 {
 		return nil, fmt.Errorf("-p argument value must be greater then 0, provided: %v", pollSec)
 	}
+	if cfg.RateLimit <= 0 {
+		return nil, fmt.Errorf("-l argument value must be greater then 0, provided: %v", cfg.RateLimit)
+	}
 	cfg.ReportInterval = time.Duration(reportSec) * time.Second
 	cfg.PollInterval = time.Duration(pollSec) * time.Second
 
+	delays, err := parseDurations(retryDelays)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry delays %q: %w", retryDelays, err)
+	}
+	cfg.RetryDelays = delays
+
 	return cfg, nil
 }
+
+// parseDurations parses a comma-separated list like "1s,3s,5s".
+func parseDurations(s string) ([]time.Duration, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]time.Duration, 0, len(parts))
+	for _, p := range parts {
+		d, err := time.ParseDuration(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("negative duration %s", d)
+		}
+		out = append(out, d)
+	}
+
+	return out, nil
+}
+
+// parseBuckets parses a comma-separated list of strictly increasing bounds like "0.1,0.5,1".
+func parseBuckets(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("bound %v is not finite", v)
+		}
+		if len(out) > 0 && v <= out[len(out)-1] {
+			return nil, fmt.Errorf("bounds must be strictly increasing")
+		}
+		out = append(out, v)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,175 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Header marks an encrypted request body; its value names the scheme.
+const (
+	Header = "X-Encrypted"
+	Scheme = "rsa-oaep-aes256gcm"
+)
+
+// Envelope layout:
+//
+//	version (1 byte) | wrapped key length (2 bytes, big endian) | wrapped key |
+//	nonce (12 bytes) | AES-256-GCM ciphertext with tag
+//
+// The AES key is random per message and wrapped with RSA-OAEP (SHA-256).
+const (
+	envelopeVersion = 1
+	aesKeySize      = 32
+)
+
+var errMalformed = errors.New("malformed encrypted message")
+
+// Encrypt seals plaintext for the owner of pub.
+func Encrypt(pub *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	key := make([]byte, aesKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrap key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 3+len(wrapped)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, envelopeVersion)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens a message produced by Encrypt.
+func Decrypt(priv *rsa.PrivateKey, msg []byte) ([]byte, error) {
+	if len(msg) < 3 || msg[0] != envelopeVersion {
+		return nil, errMalformed
+	}
+	n := int(binary.BigEndian.Uint16(msg[1:3]))
+	msg = msg[3:]
+	if len(msg) < n {
+		return nil, errMalformed
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, priv, msg[:n], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key: %w", err)
+	}
+	msg = msg[n:]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) < gcm.NonceSize() {
+		return nil, errMalformed
+	}
+	nonce, ciphertext := msg[:gcm.NonceSize()], msg[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// LoadPublicKey reads an RSA public key from a PEM file.
+// PKIX ("PUBLIC KEY"), PKCS#1 ("RSA PUBLIC KEY") and certificates are accepted.
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an RSA public key", path)
+	}
+
+	return pub, nil
+}
+
+// LoadPrivateKey reads an RSA private key from a PEM file in PKCS#1 or PKCS#8 form.
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse private key: %w", err)
+		}
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s does not contain an RSA private key", path)
+		}
+
+		return priv, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	return block, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,105 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	return priv
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	priv := newTestKey(t)
+	plaintext := bytes.Repeat([]byte("metrics"), 1000)
+
+	msg, err := Encrypt(&priv.PublicKey, plaintext)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if bytes.Contains(msg, []byte("metrics")) {
+		t.Fatalf("ciphertext contains plaintext")
+	}
+
+	got, err := Decrypt(priv, msg)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("decrypted data differs")
+	}
+}
+
+func TestDecrypt_Rejects(t *testing.T) {
+	priv := newTestKey(t)
+	msg, err := Encrypt(&priv.PublicKey, []byte("payload"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tampered := bytes.Clone(msg)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, err := Decrypt(priv, tampered); err == nil {
+		t.Fatalf("tampered message decrypted")
+	}
+	if _, err := Decrypt(newTestKey(t), msg); err == nil {
+		t.Fatalf("message decrypted with a wrong key")
+	}
+	if _, err := Decrypt(priv, msg[:10]); err == nil {
+		t.Fatalf("truncated message decrypted")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	priv := newTestKey(t)
+	dir := t.TempDir()
+
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	pubPath := filepath.Join(dir, "public.pem")
+	privPath := filepath.Join(dir, "private.pem")
+	writeFile(t, pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	writeFile(t, privPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}))
+
+	pub, err := LoadPublicKey(pubPath)
+	if err != nil {
+		t.Fatalf("load public key: %v", err)
+	}
+	loaded, err := LoadPrivateKey(privPath)
+	if err != nil {
+		t.Fatalf("load private key: %v", err)
+	}
+
+	msg, err := Encrypt(pub, []byte("payload"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if got, err := Decrypt(loaded, msg); err != nil || string(got) != "payload" {
+		t.Fatalf("round trip with loaded keys: %q %v", got, err)
+	}
+
+	if _, err := LoadPrivateKey(pubPath); err == nil {
+		t.Fatalf("public key accepted as private key")
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
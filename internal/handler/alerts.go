@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/xGuthub/metrics-collection-service/internal/alert"
+)
+
+// AlertLister provides the current alert states.
+type AlertLister interface {
+	Alerts() []alert.Alert
+}
+
+// SetAlerts enables the alerts API and the alerts section of the metrics page.
+func (mh *MetricsHandler) SetAlerts(alerts AlertLister) {
+	mh.alerts = alerts
+}
+
+// AlertsHandler serves GET /api/v1/alerts: pending, firing and recently
+// resolved alerts. The list is empty when no rules are configured.
+func (mh *MetricsHandler) AlertsHandler(w http.ResponseWriter, _ *http.Request) {
+	alerts := []alert.Alert{}
+	if mh.alerts != nil {
+		alerts = mh.alerts.Alerts()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"alerts": alerts})
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// AnomaliesHandler serves GET /api/v1/anomalies?metric=&limit=: the recent
+// points flagged by the anomaly detector, newest first.
+func (mh *MetricsHandler) AnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := -1
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		limit = n
+	}
+
+	anomalies, err := mh.metricsService.Anomalies(q.Get("metric"))
+	if err != nil {
+		if err.Error() == "anomaly detection disabled" {
+			writePlain(w, http.StatusNotFound, "anomaly detection disabled")
+
+			return
+		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+	if limit >= 0 && len(anomalies) > limit {
+		anomalies = anomalies[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"anomalies": anomalies})
+}
@@ -0,0 +1,98 @@
+// Client-side filtering, sorting and auto-refresh for the metrics page.
+// The state is kept in the query string so it survives page reloads.
+(function () {
+  "use strict";
+
+  var params = new URLSearchParams(window.location.search);
+  var filter = document.getElementById("filter");
+  var sort = document.getElementById("sort");
+  var refresh = document.getElementById("refresh");
+  var refreshTimer = null;
+
+  filter.value = params.get("q") || "";
+  sort.value = params.get("sort") || "name";
+  refresh.value = params.get("refresh") || "0";
+
+  // Items are rendered as "<name>: <value>"; values never contain ": ".
+  function parseItem(li) {
+    var text = li.textContent;
+    var i = text.lastIndexOf(": ");
+    if (i < 0) {
+      return null;
+    }
+    return { name: text.slice(0, i), value: parseFloat(text.slice(i + 2)) };
+  }
+
+  function compare(a, b) {
+    switch (sort.value) {
+      case "value-asc":
+        return a.metric.value - b.metric.value;
+      case "value-desc":
+        return b.metric.value - a.metric.value;
+      default:
+        return a.metric.name < b.metric.name ? -1 : a.metric.name > b.metric.name ? 1 : 0;
+    }
+  }
+
+  function render() {
+    var needle = filter.value.toLowerCase();
+    document.querySelectorAll("ul.metrics").forEach(function (ul) {
+      var items = [];
+      ul.querySelectorAll("li").forEach(function (li) {
+        var metric = parseItem(li);
+        if (metric) {
+          items.push({ li: li, metric: metric });
+        }
+      });
+      items.sort(compare);
+      items.forEach(function (it) {
+        it.li.classList.toggle("hidden", it.metric.name.toLowerCase().indexOf(needle) < 0);
+        ul.appendChild(it.li);
+      });
+    });
+  }
+
+  function saveState() {
+    var p = new URLSearchParams();
+    if (filter.value) {
+      p.set("q", filter.value);
+    }
+    if (sort.value !== "name") {
+      p.set("sort", sort.value);
+    }
+    if (refresh.value !== "0") {
+      p.set("refresh", refresh.value);
+    }
+    var qs = p.toString();
+    window.history.replaceState(null, "", window.location.pathname + (qs ? "?" + qs : ""));
+  }
+
+  function scheduleRefresh() {
+    if (refreshTimer) {
+      clearTimeout(refreshTimer);
+      refreshTimer = null;
+    }
+    var seconds = parseInt(refresh.value, 10);
+    if (seconds > 0) {
+      refreshTimer = setTimeout(function () {
+        window.location.reload();
+      }, seconds * 1000);
+    }
+  }
+
+  filter.addEventListener("input", function () {
+    saveState();
+    render();
+  });
+  sort.addEventListener("change", function () {
+    saveState();
+    render();
+  });
+  refresh.addEventListener("change", function () {
+    saveState();
+    scheduleRefresh();
+  });
+
+  render();
+  scheduleRefresh();
+})();
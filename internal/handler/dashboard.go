@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xGuthub/metrics-collection-service/internal/alert"
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+//go:embed assets
+var assetsFS embed.FS
+
+var homeTemplate = template.Must(template.ParseFS(templatesFS, "templates/home.html"))
+
+var assetsServer = func() http.Handler {
+	sub, err := fs.Sub(assetsFS, "assets")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.StripPrefix("/assets/", http.FileServer(http.FS(sub)))
+}()
+
+// dashboardMetric is a single row of the metrics page.
+type dashboardMetric struct {
+	Name  string
+	Value string
+	// Details are shown after the value; they must not contain ": ",
+	// the page script splits rows on its last occurrence.
+	Details string
+}
+
+type homePage struct {
+	Gauges     []dashboardMetric
+	Counters   []dashboardMetric
+	Histograms []dashboardMetric
+	Summaries  []dashboardMetric
+	// Sets show the estimated number of distinct members.
+	Sets []dashboardMetric
+	// AlertsEnabled shows the alerts section when rules are configured.
+	AlertsEnabled bool
+	Alerts        []alert.Alert
+}
+
+// summaryQuantiles are the quantiles shown for summaries on the page and in /metrics.
+var summaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// histogramRow shows the observation count as the value, followed by
+// the sum and the per-bucket counts.
+func histogramRow(name string, h *sketch.Histogram) dashboardMetric {
+	parts := make([]string, 0, len(h.Counts)+1)
+	parts = append(parts, "sum "+strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	for i, c := range h.Counts {
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+		}
+		parts = append(parts, "≤"+le+" "+strconv.FormatUint(c, 10))
+	}
+
+	return dashboardMetric{
+		Name:    name,
+		Value:   strconv.FormatUint(h.Count, 10),
+		Details: strings.Join(parts, ", "),
+	}
+}
+
+// AssetsHandler serves the embedded static files of the metrics page.
+func (mh *MetricsHandler) AssetsHandler(w http.ResponseWriter, r *http.Request) {
+	assetsServer.ServeHTTP(w, r)
+}
+
+// summaryRow shows the number of observations in the current window as the
+// value, followed by the sum and the common quantiles.
+func summaryRow(name string, td *sketch.TDigest) dashboardMetric {
+	parts := make([]string, 0, len(summaryQuantiles)+1)
+	parts = append(parts, "sum "+strconv.FormatFloat(td.Sum, 'g', -1, 64))
+	if td.Count > 0 {
+		for _, q := range summaryQuantiles {
+			parts = append(parts, "p"+strconv.FormatFloat(q*100, 'g', -1, 64)+" "+
+				strconv.FormatFloat(td.Quantile(q), 'g', 6, 64))
+		}
+	}
+
+	return dashboardMetric{
+		Name:    name,
+		Value:   strconv.FormatFloat(td.Count, 'g', -1, 64),
+		Details: strings.Join(parts, ", "),
+	}
+}
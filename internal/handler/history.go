@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// defaultHistoryRange is the lookback of a history query without "from".
+const defaultHistoryRange = time.Hour
+
+type historyResponse struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name"`
+	Points []models.Point `json:"points"`
+}
+
+// HistoryHandler serves GET /api/v1/history/{type}/{name}?from=&to=&step=.
+// from and to are RFC 3339 or Unix seconds and default to the last hour;
+// step is a duration like 30s or a number of seconds, raw samples when omitted.
+func (mh *MetricsHandler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/history/")
+	mType, name, ok := strings.Cut(rest, "/")
+	if !ok || name == "" {
+		writePlain(w, http.StatusNotFound, "not found")
+
+		return
+	}
+
+	q := r.URL.Query()
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultHistoryRange)
+	if v := q.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		from = t
+	}
+	var step time.Duration
+	if v := q.Get("step"); v != "" {
+		d, err := parseStepParam(v)
+		if err != nil {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		step = d
+	}
+
+	points, err := mh.metricsService.History(mType, name, from, to, step)
+	if err != nil {
+		switch err.Error() {
+		case "bad metric type":
+			writePlain(w, http.StatusBadRequest, "bad metric type")
+		case "bad value":
+			writePlain(w, http.StatusBadRequest, "bad value")
+		case "history disabled":
+			writePlain(w, http.StatusNotFound, "history disabled")
+		default:
+			writePlain(w, http.StatusInternalServerError, "internal error")
+		}
+
+		return
+	}
+	if points == nil {
+		points = []models.Point{}
+	}
+
+	writeJSON(w, http.StatusOK, historyResponse{Type: mType, Name: name, Points: points})
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	sec, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return time.Time{}, errors.New("bad time")
+	}
+
+	return time.UnixMilli(int64(sec * 1000)), nil
+}
+
+func parseStepParam(v string) (time.Duration, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, nil
+	}
+	sec, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return 0, errors.New("bad step")
+	}
+
+	return time.Duration(sec * float64(time.Second)), nil
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/repository"
+	"github.com/xGuthub/metrics-collection-service/internal/service"
+)
+
+func getHistory(t *testing.T, h *MetricsHandler, target string) (int, historyResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.HistoryHandler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp historyResponse
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+
+	return rr.Code, resp
+}
+
+func TestHistoryHandler(t *testing.T) {
+	h, svc := newTestHandler()
+
+	// Disabled until a store is injected
+	if code, _ := getHistory(t, h, "/api/v1/history/gauge/temp"); code != http.StatusNotFound {
+		t.Fatalf("disabled: expected %d, got %d", http.StatusNotFound, code)
+	}
+
+	history := repository.NewMemHistory(time.Hour, 100)
+	svc.SetHistory(history)
+
+	for _, v := range []string{"1", "2", "3"} {
+		if err := svc.UpdateMetric(models.Gauge, "temp", v); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+	// Counters record their running total, batches feed the history too
+	d := int64(5)
+	if err := svc.UpdateMetrics([]models.Metrics{{ID: "hits", MType: models.Counter, Delta: &d}}); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if err := svc.UpdateMetric(models.Counter, "hits", "2"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	code, resp := getHistory(t, h, "/api/v1/history/gauge/temp")
+	if code != http.StatusOK || len(resp.Points) != 3 || resp.Points[2].Value != 3 {
+		t.Fatalf("gauge history: %d %+v", code, resp)
+	}
+	code, resp = getHistory(t, h, "/api/v1/history/counter/hits")
+	if code != http.StatusOK || len(resp.Points) != 2 || resp.Points[0].Value != 5 || resp.Points[1].Value != 7 {
+		t.Fatalf("counter history: %d %+v", code, resp)
+	}
+
+	// Synthetic samples with known timestamps for the range and step checks
+	base := time.Now().Add(-10 * time.Minute).Truncate(time.Minute)
+	for i := 0; i < 6; i++ {
+		_ = history.Append("gauge/load", base.Add(time.Duration(i)*20*time.Second), float64(i))
+	}
+	from := strconv.FormatInt(base.Unix(), 10)
+	to := base.Add(2 * time.Minute).Format(time.RFC3339)
+
+	code, resp = getHistory(t, h, "/api/v1/history/gauge/load?from="+from+"&to="+to+"&step=1m")
+	if code != http.StatusOK || len(resp.Points) != 2 {
+		t.Fatalf("stepped history: %d %+v", code, resp)
+	}
+	// Each window is represented by its last sample
+	if resp.Points[0].Timestamp != base.UnixMilli() || resp.Points[0].Value != 2 || resp.Points[1].Value != 5 {
+		t.Fatalf("unexpected windows: %+v", resp.Points)
+	}
+
+	code, resp = getHistory(t, h, "/api/v1/history/gauge/load?from="+from+"&to="+from)
+	if code != http.StatusOK || len(resp.Points) != 1 || resp.Points[0].Value != 0 {
+		t.Fatalf("point range: %d %+v", code, resp)
+	}
+
+	for _, target := range []string{
+		"/api/v1/history/histogram/load",
+		"/api/v1/history/gauge/load?from=yesterday",
+		"/api/v1/history/gauge/load?step=-1s",
+		"/api/v1/history/gauge/load?from=" + to + "&to=" + from,
+	} {
+		if code, _ := getHistory(t, h, target); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected %d, got %d", target, http.StatusBadRequest, code)
+		}
+	}
+
+	if code, resp := getHistory(t, h, "/api/v1/history/gauge/missing"); code != http.StatusOK || resp.Points == nil {
+		t.Fatalf("missing series must be an empty list: %d %+v", code, resp)
+	}
+}
+
+func TestHistoryHandlerSubMillisecondStep(t *testing.T) {
+	h, svc := newTestHandler()
+	svc.SetHistory(repository.NewMemHistory(time.Hour, 100))
+	if err := svc.UpdateMetric(models.Gauge, "g", "1"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	rng := "from=" + strconv.FormatFloat(now-1, 'f', 3, 64) + "&to=" + strconv.FormatFloat(now+1, 'f', 3, 64)
+	for _, step := range []string{"500us", "1ns", "0.0001"} {
+		target := "/api/v1/history/gauge/g?" + rng + "&step=" + step
+		if code, _ := getHistory(t, h, target); code != http.StatusBadRequest {
+			t.Fatalf("step=%s: expected %d, got %d", step, http.StatusBadRequest, code)
+		}
+	}
+
+	code, resp := getHistory(t, h, "/api/v1/history/gauge/g?"+rng+"&step=1ms")
+	if code != http.StatusOK || len(resp.Points) != 1 {
+		t.Fatalf("step=1ms: %d %+v", code, resp)
+	}
+}
+
+func TestHistory_RecordedWithoutReadBack(t *testing.T) {
+	// Reads fail, so every recorded point comes from the write itself
+	svc := service.NewMetricsService(failingStorage{repository.NewMemStorage()})
+	svc.SetHistory(repository.NewMemHistory(time.Hour, 100))
+	h := NewMetricsHandler(svc)
+
+	if err := svc.UpdateMetric(models.Gauge, "temp", "1.5"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := svc.UpdateMetric(models.Counter, "hits", "2"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	d := int64(3)
+	if err := svc.UpdateMetrics([]models.Metrics{{ID: "hits", MType: models.Counter, Delta: &d}}); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+
+	code, resp := getHistory(t, h, "/api/v1/history/gauge/temp")
+	if code != http.StatusOK || len(resp.Points) != 1 || resp.Points[0].Value != 1.5 {
+		t.Fatalf("gauge history: %d %+v", code, resp)
+	}
+	code, resp = getHistory(t, h, "/api/v1/history/counter/hits")
+	if code != http.StatusOK || len(resp.Points) != 2 || resp.Points[0].Value != 2 || resp.Points[1].Value != 5 {
+		t.Fatalf("counter history: %d %+v", code, resp)
+	}
+}
+
+// rejectingHistory refuses every sample.
+type rejectingHistory struct {
+	service.HistoryStore
+}
+
+func (rejectingHistory) Append(string, time.Time, float64) error { return repository.ErrOutOfOrder }
+
+func TestHistory_RejectedSampleReported(t *testing.T) {
+	_, svc := newTestHandler()
+	svc.SetHistory(rejectingHistory{})
+	var reported []error
+	svc.SetHistoryErrorHandler(func(err error) { reported = append(reported, err) })
+
+	// The write still succeeds, the dropped sample is reported
+	if err := svc.UpdateMetric(models.Gauge, "temp", "1"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], repository.ErrOutOfOrder) {
+		t.Fatalf("expected one reported ErrOutOfOrder, got %v", reported)
+	}
+}
@@ -1,11 +1,11 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"math"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -15,6 +15,8 @@ import (
 
 type MetricsHandler struct {
 	metricsService *service.MetricsService
+	alerts         AlertLister
+	silences       SilenceStore
 }
 
 func NewMetricsHandler(metricsService *service.MetricsService) *MetricsHandler {
@@ -24,47 +26,82 @@ func NewMetricsHandler(metricsService *service.MetricsService) *MetricsHandler {
 }
 
 func (mh *MetricsHandler) HomeHandler(w http.ResponseWriter, _ *http.Request) {
-	gauges := mh.metricsService.AllGauges()
-	counters := mh.metricsService.AllCounters()
+	gauges, err := mh.metricsService.AllGauges()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
 
-	gNames := make([]string, 0, len(gauges))
-	for name := range gauges {
-		gNames = append(gNames, name)
+		return
 	}
-	sort.Strings(gNames)
+	counters, err := mh.metricsService.AllCounters()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
 
-	cNames := make([]string, 0, len(counters))
-	for name := range counters {
-		cNames = append(cNames, name)
+		return
 	}
-	sort.Strings(cNames)
+	histograms, err := mh.metricsService.AllHistograms()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
 
-	body := "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Metrics</title></head><body>"
-	body += "<h1>Metrics</h1>"
+		return
+	}
+	summaries, err := mh.metricsService.SummarySnapshots()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
 
-	body += "<h2>Gauges</h2><ul>"
-	if len(gNames) == 0 {
-		body += "<li><em>No gauges</em></li>"
-	} else {
-		for _, name := range gNames {
-			body += fmt.Sprintf("<li>%s: %s</li>", name, strconv.FormatFloat(gauges[name], 'g', -1, 64))
-		}
+		return
 	}
-	body += "</ul>"
+	sets, err := mh.metricsService.AllSetCardinalities()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
 
-	body += "<h2>Counters</h2><ul>"
-	if len(cNames) == 0 {
-		body += "<li><em>No counters</em></li>"
-	} else {
-		for _, name := range cNames {
-			body += fmt.Sprintf("<li>%s: %d</li>", name, counters[name])
-		}
+		return
+	}
+
+	page := homePage{
+		Gauges:     make([]dashboardMetric, 0, len(gauges)),
+		Counters:   make([]dashboardMetric, 0, len(counters)),
+		Histograms: make([]dashboardMetric, 0, len(histograms)),
+		Summaries:  make([]dashboardMetric, 0, len(summaries)),
+		Sets:       make([]dashboardMetric, 0, len(sets)),
+	}
+	if mh.alerts != nil {
+		page.AlertsEnabled = true
+		page.Alerts = mh.alerts.Alerts()
+	}
+	for _, name := range sortedKeys(gauges) {
+		page.Gauges = append(page.Gauges, dashboardMetric{
+			Name:  name,
+			Value: strconv.FormatFloat(gauges[name], 'g', -1, 64),
+		})
+	}
+	for _, name := range sortedKeys(counters) {
+		page.Counters = append(page.Counters, dashboardMetric{
+			Name:  name,
+			Value: strconv.FormatInt(counters[name], 10),
+		})
+	}
+	for _, name := range sortedKeys(histograms) {
+		page.Histograms = append(page.Histograms, histogramRow(name, histograms[name]))
+	}
+	for _, name := range sortedKeys(summaries) {
+		page.Summaries = append(page.Summaries, summaryRow(name, summaries[name]))
+	}
+	for _, name := range sortedKeys(sets) {
+		page.Sets = append(page.Sets, dashboardMetric{
+			Name:  name,
+			Value: strconv.FormatUint(sets[name], 10),
+		})
 	}
-	body += "</ul>"
 
-	body += "</body></html>"
+	// Render into a buffer first so a template error still yields a clean 500
+	var buf bytes.Buffer
+	if err := homeTemplate.Execute(&buf, page); err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
 
-	writeHTML(w, http.StatusOK, body)
+	writeHTML(w, http.StatusOK, buf.String())
 }
 
 func (mh *MetricsHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
@@ -86,6 +123,13 @@ func (mh *MetricsHandler) UpdateHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The URL path API addresses unlabeled series only
+	if err := service.ValidateSeries(models.Metrics{ID: name}); err != nil {
+		writePlain(w, http.StatusBadRequest, "bad value")
+
+		return
+	}
+
 	err = mh.metricsService.UpdateMetric(mType, name, rawVal)
 
 	if err != nil {
@@ -99,6 +143,9 @@ func (mh *MetricsHandler) UpdateHandler(w http.ResponseWriter, r *http.Request)
 
 			return
 		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
 	}
 
 	writePlain(w, http.StatusOK, "OK")
@@ -118,12 +165,19 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if m.ID == "" {
+	if err := service.ValidateSeries(m); err != nil {
 		writePlain(w, http.StatusBadRequest, "bad value")
 
 		return
 	}
 
+	if err := mh.metricsService.VerifyMetricHash(m); err != nil {
+		// "hash mismatch" or "missing hash"
+		writePlain(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
 	switch m.MType {
 	case models.Gauge:
 		if m.Value == nil {
@@ -132,7 +186,7 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		raw := strconv.FormatFloat(*m.Value, 'g', -1, 64)
-		if err := mh.metricsService.UpdateMetric(models.Gauge, m.ID, raw); err != nil {
+		if err := mh.metricsService.UpdateMetric(models.Gauge, m.Key(), raw); err != nil {
 			if err.Error() == "bad value" {
 				writePlain(w, http.StatusBadRequest, "bad value")
 
@@ -143,6 +197,9 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 
 				return
 			}
+			writePlain(w, http.StatusInternalServerError, "internal error")
+
+			return
 		}
 	case models.Counter:
 		if m.Delta == nil {
@@ -151,7 +208,62 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		raw := strconv.FormatInt(*m.Delta, 10)
-		if err := mh.metricsService.UpdateMetric(models.Counter, m.ID, raw); err != nil {
+		if err := mh.metricsService.UpdateMetric(models.Counter, m.Key(), raw); err != nil {
+			if err.Error() == "bad value" {
+				writePlain(w, http.StatusBadRequest, "bad value")
+
+				return
+			}
+			if err.Error() == "bad metric type" {
+				writePlain(w, http.StatusBadRequest, "bad metric type")
+
+				return
+			}
+			writePlain(w, http.StatusInternalServerError, "internal error")
+
+			return
+		}
+	case models.Histogram:
+		if m.Value == nil {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		if err := mh.metricsService.ObserveHistogram(m.Key(), m.Buckets, *m.Value); err != nil {
+			if err.Error() == "bad value" {
+				writePlain(w, http.StatusBadRequest, "bad value")
+
+				return
+			}
+			if err.Error() == "bad metric type" {
+				writePlain(w, http.StatusBadRequest, "bad metric type")
+
+				return
+			}
+			writePlain(w, http.StatusInternalServerError, "internal error")
+
+			return
+		}
+
+		// Respond with the accumulated histogram instead of the observation
+		h, err := mh.metricsService.GetHistogram(m.Key())
+		if err != nil {
+			writeReadError(w, err)
+
+			return
+		}
+		m.Value, m.Buckets, m.Histogram = nil, nil, h
+		mh.metricsService.SignMetric(&m)
+		writeJSON(w, http.StatusOK, m)
+
+		return
+	case models.Summary:
+		if m.Value == nil {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		if err := mh.metricsService.ObserveSummary(m.Key(), *m.Value); err != nil {
 			if err.Error() == "bad value" {
 				writePlain(w, http.StatusBadRequest, "bad value")
 
@@ -162,6 +274,36 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 
 				return
 			}
+			writePlain(w, http.StatusInternalServerError, "internal error")
+
+			return
+		}
+
+		// Respond with the requested quantile instead of the observation
+		if err := mh.fillSummaryQuantile(&m); err != nil {
+			writeReadError(w, err)
+
+			return
+		}
+		mh.metricsService.SignMetric(&m)
+		writeJSON(w, http.StatusOK, m)
+
+		return
+	case models.Set:
+		if err := mh.metricsService.AddSetMember(m.Key(), m.Member); err != nil {
+			if err.Error() == "bad value" {
+				writePlain(w, http.StatusBadRequest, "bad value")
+
+				return
+			}
+			if err.Error() == "bad metric type" {
+				writePlain(w, http.StatusBadRequest, "bad metric type")
+
+				return
+			}
+			writePlain(w, http.StatusInternalServerError, "internal error")
+
+			return
 		}
 	default:
 		writePlain(w, http.StatusBadRequest, "bad metric type")
@@ -170,14 +312,16 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Build JSON response with the current stored value
-	cur, err := mh.metricsService.GetMetric(m.MType, m.ID)
+	cur, err := mh.metricsService.GetMetric(m.MType, m.Key())
 	if err != nil {
-		if err.Error() == "bad metric type" {
+		switch err.Error() {
+		case "bad metric type":
 			writePlain(w, http.StatusBadRequest, "bad metric type")
-
-			return
+		case "not found":
+			writePlain(w, http.StatusNotFound, "bad value")
+		default:
+			writePlain(w, http.StatusInternalServerError, "internal error")
 		}
-		writePlain(w, http.StatusNotFound, "bad value")
 
 		return
 	}
@@ -187,17 +331,83 @@ func (mh *MetricsHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reque
 		if v, err := strconv.ParseFloat(cur, 64); err == nil {
 			m.Value = &v
 		}
-	case models.Counter:
+	case models.Counter, models.Set:
 		if v, err := strconv.ParseInt(cur, 10, 64); err == nil {
 			m.Delta = &v
 		}
 	}
 
+	mh.metricsService.SignMetric(&m)
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(m)
 }
 
+// UpdatesJSONHandler accepts a JSON array of metrics and applies it atomically.
+// If any element is invalid, the whole batch is rejected and the response
+// lists the errors for each rejected element.
+func (mh *MetricsHandler) UpdatesJSONHandler(w http.ResponseWriter, r *http.Request) {
+	if err := validateContentType(r, "application/json"); err != nil {
+		writePlain(w, http.StatusUnsupportedMediaType, "unsupported media type: expected application/json")
+
+		return
+	}
+
+	var batch []models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		writePlain(w, http.StatusBadRequest, "bad value")
+
+		return
+	}
+	if len(batch) == 0 {
+		writePlain(w, http.StatusBadRequest, "empty batch")
+
+		return
+	}
+
+	if err := mh.metricsService.UpdateMetrics(batch); err != nil {
+		var batchErr *service.BatchError
+		if errors.As(err, &batchErr) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": batchErr.Items})
+
+			return
+		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+
+	// Respond with the current stored value of every updated metric
+	out := make([]models.Metrics, 0, len(batch))
+	for _, m := range batch {
+		cur, err := mh.metricsService.GetMetric(m.MType, m.Key())
+		if err != nil {
+			if err.Error() == "not found" {
+				continue
+			}
+			writePlain(w, http.StatusInternalServerError, "internal error")
+
+			return
+		}
+		res := models.Metrics{ID: m.ID, MType: m.MType, Labels: m.Labels}
+		switch m.MType {
+		case models.Gauge:
+			if v, err := strconv.ParseFloat(cur, 64); err == nil {
+				res.Value = &v
+			}
+		case models.Counter:
+			if v, err := strconv.ParseInt(cur, 10, 64); err == nil {
+				res.Delta = &v
+			}
+		}
+		mh.metricsService.SignMetric(&res)
+		out = append(out, res)
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
 func (mh *MetricsHandler) ValueHandler(w http.ResponseWriter, r *http.Request) {
 	mType, name, _, err := parsePath(r.URL.Path)
 	if err != nil {
@@ -211,6 +421,25 @@ func (mh *MetricsHandler) ValueHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Summaries answer a quantile, ?q=0.99; the median by default
+	if mType == models.Summary && r.URL.Query().Has("q") {
+		q, err := strconv.ParseFloat(r.URL.Query().Get("q"), 64)
+		if err != nil {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		v, err := mh.metricsService.SummaryQuantile(name, q)
+		if err != nil {
+			writeReadError(w, err)
+
+			return
+		}
+		writePlain(w, http.StatusOK, strconv.FormatFloat(v, 'g', -1, 64))
+
+		return
+	}
+
 	val, err := mh.metricsService.GetMetric(mType, name)
 
 	if err != nil {
@@ -224,6 +453,9 @@ func (mh *MetricsHandler) ValueHandler(w http.ResponseWriter, r *http.Request) {
 
 			return
 		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
 	}
 
 	writePlain(w, http.StatusOK, val)
@@ -243,22 +475,44 @@ func (mh *MetricsHandler) ValueJSONHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if m.ID == "" {
+	if err := service.ValidateSeries(m); err != nil {
 		writePlain(w, http.StatusBadRequest, "bad value")
 
 		return
 	}
 
 	switch m.MType {
-	case models.Gauge, models.Counter:
+	case models.Gauge, models.Counter, models.Set:
 		// ok
+	case models.Histogram:
+		h, err := mh.metricsService.GetHistogram(m.Key())
+		if err != nil {
+			writeReadError(w, err)
+
+			return
+		}
+		m.Delta, m.Value, m.Buckets, m.Histogram = nil, nil, nil, h
+		mh.metricsService.SignMetric(&m)
+		writeJSON(w, http.StatusOK, m)
+
+		return
+	case models.Summary:
+		if err := mh.fillSummaryQuantile(&m); err != nil {
+			writeReadError(w, err)
+
+			return
+		}
+		mh.metricsService.SignMetric(&m)
+		writeJSON(w, http.StatusOK, m)
+
+		return
 	default:
 		writePlain(w, http.StatusBadRequest, "bad metric type")
 
 		return
 	}
 
-	cur, err := mh.metricsService.GetMetric(m.MType, m.ID)
+	cur, err := mh.metricsService.GetMetric(m.MType, m.Key())
 	if err != nil {
 		if err.Error() == "bad metric type" {
 			writePlain(w, http.StatusBadRequest, "bad metric type")
@@ -270,7 +524,7 @@ func (mh *MetricsHandler) ValueJSONHandler(w http.ResponseWriter, r *http.Reques
 
 			return
 		}
-		writePlain(w, http.StatusBadRequest, "bad value")
+		writePlain(w, http.StatusInternalServerError, "internal error")
 
 		return
 	}
@@ -281,30 +535,82 @@ func (mh *MetricsHandler) ValueJSONHandler(w http.ResponseWriter, r *http.Reques
 			m.Value = &v
 		}
 		m.Delta = nil
-	case models.Counter:
+	case models.Counter, models.Set:
 		if v, err := strconv.ParseInt(cur, 10, 64); err == nil {
 			m.Delta = &v
 		}
-		m.Value = nil
+		m.Value, m.Member = nil, ""
 	}
 
+	mh.metricsService.SignMetric(&m)
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(m)
 }
 
+// fillSummaryQuantile sets m.Value to the quantile selected by m.Quantile,
+// defaulting to the median. Value stays empty when the window has no data.
+func (mh *MetricsHandler) fillSummaryQuantile(m *models.Metrics) error {
+	q := 0.5
+	if m.Quantile != nil {
+		q = *m.Quantile
+	}
+	v, err := mh.metricsService.SummaryQuantile(m.Key(), q)
+	if err != nil {
+		return err
+	}
+	m.Delta, m.Value, m.Quantile = nil, nil, &q
+	if !math.IsNaN(v) {
+		m.Value = &v
+	}
+
+	return nil
+}
+
+// PingHandler reports whether the storage database is reachable.
+func (mh *MetricsHandler) PingHandler(w http.ResponseWriter, r *http.Request) {
+	if err := mh.metricsService.Ping(r.Context()); err != nil {
+		writePlain(w, http.StatusInternalServerError, "database unavailable")
+
+		return
+	}
+
+	writePlain(w, http.StatusOK, "OK")
+}
+
 func writeHTML(w http.ResponseWriter, status int, msg string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(status)
 	_, _ = w.Write([]byte(msg))
 }
 
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func writePlain(w http.ResponseWriter, status int, msg string) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(status)
 	_, _ = w.Write([]byte(msg))
 }
 
+// writeReadError answers a failed metric read; storage failures are 500.
+func writeReadError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "not found":
+		writePlain(w, http.StatusNotFound, "bad value")
+	case "bad metric type":
+		writePlain(w, http.StatusBadRequest, "bad metric type")
+	case "bad value":
+		writePlain(w, http.StatusBadRequest, "bad value")
+	default:
+		writePlain(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
 func validateContentType(r *http.Request, contType string) error {
 	ct := r.Header.Get("Content-Type")
 	if ct == "" {
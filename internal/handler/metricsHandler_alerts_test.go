@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/alert"
+)
+
+func TestAlertsHandler_Disabled(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rr := httptest.NewRecorder()
+	h.AlertsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"alerts":[]}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HomeHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rr.Body.String(), "<h2>Alerts</h2>") {
+		t.Fatalf("alerts section must be hidden without rules")
+	}
+}
+
+func TestAlertsHandler_Firing(t *testing.T) {
+	h, svc := newTestHandler()
+	if err := svc.UpdateMetric("gauge", "HeapAlloc", "90"); err != nil {
+		t.Fatalf("seed gauge: %v", err)
+	}
+	rules := []alert.Rule{{Name: "HighHeap", Metric: "HeapAlloc", Type: "gauge", Op: ">", Threshold: 50}}
+	evaluator := alert.NewEvaluator(rules, svc)
+	evaluator.Evaluate(time.Now())
+	h.SetAlerts(evaluator)
+
+	rr := httptest.NewRecorder()
+	h.AlertsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil))
+
+	var resp struct {
+		Alerts []alert.Alert `json:"alerts"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Alerts) != 1 || resp.Alerts[0].State != alert.StateFiring || resp.Alerts[0].Value != 90 {
+		t.Fatalf("expected one firing alert, got %+v", resp.Alerts)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HomeHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rr.Body.String()
+	for _, must := range []string{"<h2>Alerts</h2>", `<li class="firing"><strong>firing</strong> HighHeap HeapAlloc`} {
+		if !strings.Contains(body, must) {
+			t.Fatalf("response body missing %q. body=%q", must, body)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/service"
+)
+
+func getAnomalies(h *MetricsHandler, query string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.AnomaliesHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/anomalies"+query, nil))
+
+	return rr
+}
+
+func TestAnomaliesHandler(t *testing.T) {
+	h, svc := newTestHandler()
+	if rr := getAnomalies(h, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 while disabled, got %d", rr.Code)
+	}
+	svc.SetAnomalyDetection(service.AnomalyConfig{Metrics: []string{"HeapAlloc"}, Warmup: 10})
+
+	// A noisy but steady series stays inside the band
+	for i := 0; i < 40; i++ {
+		v := 100 + float64(i%2)*4
+		if err := svc.UpdateMetric("gauge", "HeapAlloc", strconv.FormatFloat(v, 'g', -1, 64)); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if err := svc.UpdateMetric("gauge", "Other", "1"); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+	if flag, err := svc.GetMetric("gauge", "HeapAlloc"+service.AnomalySuffix); err != nil || flag != "0" {
+		t.Fatalf("expected synthetic gauge 0, got %q, %v", flag, err)
+	}
+	if _, err := svc.GetMetric("gauge", "Other"+service.AnomalySuffix); err == nil {
+		t.Fatalf("unwatched metrics must not get a synthetic gauge")
+	}
+
+	if err := svc.UpdateMetric("gauge", "HeapAlloc", "500"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if flag, _ := svc.GetMetric("gauge", "HeapAlloc"+service.AnomalySuffix); flag != "1" {
+		t.Fatalf("expected synthetic gauge 1, got %q", flag)
+	}
+	// Labelled series sent in a batch are tracked on their own
+	v := 7.0
+	if err := svc.UpdateMetrics([]models.Metrics{{ID: "HeapAlloc", MType: "gauge", Value: &v, Labels: map[string]string{"host": "a"}}}); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if flag, _ := svc.GetMetric("gauge", `HeapAlloc_anomaly{host="a"}`); flag != "0" {
+		t.Fatalf("expected synthetic gauge of the labelled series, got %q", flag)
+	}
+
+	rr := getAnomalies(h, "?metric=HeapAlloc")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Anomalies []service.Anomaly `json:"anomalies"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Anomalies) != 1 || resp.Anomalies[0].Value != 500 || resp.Anomalies[0].ZScore <= 3 {
+		t.Fatalf("unexpected anomalies: %+v", resp.Anomalies)
+	}
+
+	if rr := getAnomalies(h, "?metric=Other"); rr.Body.String() != "{\"anomalies\":[]}\n" {
+		t.Fatalf("expected no anomalies for Other, got %s", rr.Body.String())
+	}
+	if rr := getAnomalies(h, "?limit=x"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad limit, got %d", rr.Code)
+	}
+}
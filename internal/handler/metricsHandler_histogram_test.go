@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+func TestHistogram_UpdateAndValueJSON(t *testing.T) {
+	h, _ := newTestHandler()
+
+	buckets := []float64{0.1, 0.5, 1}
+	for i, v := range []float64{0.05, 0.25, 0.75, 2} {
+		m := models.Metrics{ID: "latency", MType: models.Histogram, Value: &v}
+		// Buckets are declared on the first write only
+		if i == 0 {
+			m.Buckets = buckets
+		}
+		if rr := postJSON(t, h.UpdateJSONHandler, "/update/", m); rr.Code != http.StatusOK {
+			t.Fatalf("observe %v: got %d %q", v, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "latency", MType: models.Histogram})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("value: got %d %q", rr.Code, rr.Body.String())
+	}
+	var resp models.Metrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	hist := resp.Histogram
+	if hist == nil || hist.Count != 4 || hist.Sum != 3.05 {
+		t.Fatalf("unexpected histogram: %+v", hist)
+	}
+	want := []uint64{1, 1, 1, 1}
+	for i, c := range want {
+		if hist.Counts[i] != c {
+			t.Fatalf("bucket %d: got %d, want %d", i, hist.Counts[i], c)
+		}
+	}
+
+	// Declaring other buckets for an existing histogram is rejected
+	v := 1.0
+	rr = postJSON(t, h.UpdateJSONHandler, "/update/", models.Metrics{
+		ID: "latency", MType: models.Histogram, Value: &v, Buckets: []float64{1, 2},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bucket mismatch: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = postJSON(t, h.UpdateJSONHandler, "/update/", models.Metrics{
+		ID: "other", MType: models.Histogram, Value: &v, Buckets: []float64{2, 1},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unsorted buckets: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "missing", MType: models.Histogram})
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHistogram_DefaultBuckets(t *testing.T) {
+	h, svc := newTestHandler()
+	svc.SetDefaultBuckets([]float64{1, 10})
+
+	req := httptest.NewRequest(http.MethodPost, "/update/histogram/size/5", nil)
+	rr := httptest.NewRecorder()
+	h.UpdateHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	hist, err := svc.GetHistogram("size")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !hist.SameBounds([]float64{1, 10}) || hist.Counts[1] != 1 {
+		t.Fatalf("default buckets not applied: %+v", hist)
+	}
+}
+
+func TestHistogram_PageAndPrometheus(t *testing.T) {
+	h, svc := newTestHandler()
+	if err := svc.ObserveHistogram(`rt{svc="api"}`, []float64{0.5, 1}, 0.25); err != nil {
+		t.Fatalf("observe: %v", err)
+	}
+	if err := svc.ObserveHistogram(`rt{svc="api"}`, nil, 0.75); err != nil {
+		t.Fatalf("observe: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.HomeHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, `<li>rt{svc=&#34;api&#34;}: 2 <small>(sum 1, ≤0.5 1, ≤1 1, ≤&#43;Inf 0)</small></li>`) {
+		t.Fatalf("histogram row missing from page:\n%s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	h.PrometheusHandler(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := strings.Join([]string{
+		"# TYPE rt histogram",
+		`rt_bucket{le="0.5",svc="api"} 1`,
+		`rt_bucket{le="1",svc="api"} 2`,
+		`rt_bucket{le="+Inf",svc="api"} 2`,
+		`rt_sum{svc="api"} 1`,
+		`rt_count{svc="api"} 2`,
+	}, "\n") + "\n"
+	if rr.Body.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", rr.Body.String(), want)
+	}
+}
@@ -91,3 +91,43 @@ func TestHomeHandler_WithMetricsSorted(t *testing.T) {
 		t.Fatalf("placeholders should not appear when metrics exist. body=%q", body)
 	}
 }
+
+func TestHomeHandler_EscapesNames(t *testing.T) {
+	h, svc := newTestHandler()
+
+	if err := svc.UpdateMetric("gauge", "<script>alert(1)</script>", "1"); err != nil {
+		t.Fatalf("seed gauge: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	h.HomeHandler(rr, req)
+
+	body := rr.Body.String()
+	if strings.Contains(body, "<script>alert(1)</script>") {
+		t.Fatalf("metric name is not escaped. body=%q", body)
+	}
+	if !strings.Contains(body, "<li>&lt;script&gt;alert(1)&lt;/script&gt;: 1</li>") {
+		t.Fatalf("escaped metric is missing. body=%q", body)
+	}
+}
+
+func TestAssetsHandler(t *testing.T) {
+	h, _ := newTestHandler()
+
+	for _, asset := range []string{"/assets/dashboard.js", "/assets/dashboard.css"} {
+		req := httptest.NewRequest(http.MethodGet, asset, nil)
+		rr := httptest.NewRecorder()
+		h.AssetsHandler(rr, req)
+		if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+			t.Fatalf("%s: expected non-empty 200, got %d", asset, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/missing.js", nil)
+	rr := httptest.NewRecorder()
+	h.AssetsHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for missing asset, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+func postJSON(t *testing.T, handler http.HandlerFunc, path string, v any) *httptest.ResponseRecorder {
+	t.Helper()
+	buf, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(buf))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	return rr
+}
+
+func TestLabels_UpdateAndValueJSON(t *testing.T) {
+	h, svc := newTestHandler()
+
+	v1, v2 := 1.0, 2.0
+	web01 := models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v1, Labels: map[string]string{"host": "web01"}}
+	web02 := models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v2, Labels: map[string]string{"host": "web02"}}
+	for _, m := range []models.Metrics{web01, web02} {
+		if rr := postJSON(t, h.UpdateJSONHandler, "/update/", m); rr.Code != http.StatusOK {
+			t.Fatalf("update %v: got %d %q", m.Labels, rr.Code, rr.Body.String())
+		}
+	}
+
+	gauges, _ := svc.AllGauges()
+	if gauges[`Alloc{host="web01"}`] != 1 || gauges[`Alloc{host="web02"}`] != 2 {
+		t.Fatalf("labeled series not stored separately: %v", gauges)
+	}
+	if _, ok := gauges["Alloc"]; ok {
+		t.Fatalf("unlabeled series must not be created: %v", gauges)
+	}
+
+	rr := postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{
+		ID: "Alloc", MType: models.Gauge, Labels: map[string]string{"host": "web02"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("value: got %d %q", rr.Code, rr.Body.String())
+	}
+	var resp models.Metrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Value == nil || *resp.Value != 2 || resp.Labels["host"] != "web02" {
+		t.Fatalf("unexpected value response: %+v", resp)
+	}
+
+	// The unlabeled series does not exist
+	rr = postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "Alloc", MType: models.Gauge})
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unlabeled value: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestLabels_Validation(t *testing.T) {
+	h, _ := newTestHandler()
+
+	v := 1.0
+	rr := postJSON(t, h.UpdateJSONHandler, "/update/", models.Metrics{
+		ID: "Alloc", MType: models.Gauge, Value: &v, Labels: map[string]string{"bad-name": "x"},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad label name: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// Braces are reserved for the series key notation
+	req := httptest.NewRequest(http.MethodPost, `/update/gauge/a{b="c"}/1`, nil)
+	rr2 := httptest.NewRecorder()
+	h.UpdateHandler(rr2, req)
+	if rr2.Code != http.StatusBadRequest {
+		t.Fatalf("braces in path name: expected %d, got %d", http.StatusBadRequest, rr2.Code)
+	}
+}
+
+func TestLabels_Batch_Prometheus_And_Home(t *testing.T) {
+	h, _ := newTestHandler()
+
+	d1, d2 := int64(3), int64(4)
+	batch := []models.Metrics{
+		{ID: "hits", MType: models.Counter, Delta: &d1, Labels: map[string]string{"path": "/a", "code": "200"}},
+		{ID: "hits", MType: models.Counter, Delta: &d2, Labels: map[string]string{"code": "200", "path": "/a"}},
+	}
+	if rr := postJSON(t, h.UpdatesJSONHandler, "/updates/", batch); rr.Code != http.StatusOK {
+		t.Fatalf("batch: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	h.PrometheusHandler(rr, req)
+	want := "# TYPE hits counter\nhits{code=\"200\",path=\"/a\"} 7\n"
+	if got := rr.Body.String(); got != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr2 := httptest.NewRecorder()
+	h.HomeHandler(rr2, req2)
+	if body := rr2.Body.String(); !strings.Contains(body, "<li>hits{code=&#34;200&#34;,path=&#34;/a&#34;}: 7</li>") {
+		t.Fatalf("labels are not rendered on the dashboard. body=%q", body)
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusHandler_Empty(t *testing.T) {
+	h, _ := newTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	h.PrometheusHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+	if body := rr.Body.String(); body != "" {
+		t.Fatalf("expected empty body, got %q", body)
+	}
+}
+
+func TestPrometheusHandler_WithMetrics(t *testing.T) {
+	h, svc := newTestHandler()
+
+	if err := svc.UpdateMetric("gauge", "Alloc", "1.5"); err != nil {
+		t.Fatalf("seed gauge: %v", err)
+	}
+	if err := svc.UpdateMetric("gauge", "1st.value-x", "2"); err != nil {
+		t.Fatalf("seed gauge: %v", err)
+	}
+	if err := svc.UpdateMetric("counter", "PollCount", "7"); err != nil {
+		t.Fatalf("seed counter: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	h.PrometheusHandler(rr, req)
+
+	want := "# TYPE _1st_value_x gauge\n_1st_value_x 2\n" +
+		"# TYPE Alloc gauge\nAlloc 1.5\n" +
+		"# TYPE PollCount counter\nPollCount 7\n"
+	if got := rr.Body.String(); got != want {
+		t.Fatalf("unexpected body:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSanitizePrometheusName(t *testing.T) {
+	tests := map[string]string{
+		"Alloc":       "Alloc",
+		"http:req_ok": "http:req_ok",
+		"a.b-c d":     "a_b_c_d",
+		"9lives":      "_9lives",
+		"":            "_",
+	}
+	for in, want := range tests {
+		if got := sanitizePrometheusName(in); got != want || strings.ContainsAny(got, ".- ") {
+			t.Fatalf("sanitizePrometheusName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
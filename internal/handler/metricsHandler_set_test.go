@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+func TestSet_UpdateAndValue(t *testing.T) {
+	h, _ := newTestHandler()
+
+	for i := 0; i < 50; i++ {
+		// Every member is sent twice, duplicates must not be counted
+		m := models.Metrics{ID: "users", MType: models.Set, Member: "user-" + strconv.Itoa(i%25)}
+		rr := postJSON(t, h.UpdateJSONHandler, "/update/", m)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("add %q: got %d %q", m.Member, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "users", MType: models.Set})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("value: got %d %q", rr.Code, rr.Body.String())
+	}
+	var resp models.Metrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Delta == nil || *resp.Delta != 25 {
+		t.Fatalf("unexpected estimate: %+v", resp)
+	}
+
+	// The URL path API takes the member as the value
+	req := httptest.NewRequest(http.MethodPost, "/update/set/users/user-100", nil)
+	rr = httptest.NewRecorder()
+	h.UpdateHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("path update: got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	h.ValueHandler(rr, httptest.NewRequest(http.MethodGet, "/value/set/users", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "26" {
+		t.Fatalf("path value: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = postJSON(t, h.UpdateJSONHandler, "/update/", models.Metrics{ID: "users", MType: models.Set})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("empty member: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	rr = postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "missing", MType: models.Set})
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestSet_PageAndPrometheus(t *testing.T) {
+	h, svc := newTestHandler()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		if err := svc.AddSetMember("client_ips", ip); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.HomeHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rr.Body.String(), "<li>client_ips: 2</li>") {
+		t.Fatalf("set row missing from page:\n%s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.PrometheusHandler(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := "# TYPE client_ips gauge\nclient_ips 2\n"; rr.Body.String() != want {
+		t.Fatalf("unexpected exposition:\n%s", rr.Body.String())
+	}
+}
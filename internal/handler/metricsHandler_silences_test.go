@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/alert"
+)
+
+func TestSilencesHandlers(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rr := httptest.NewRecorder()
+	h.SilencesHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/silences", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without silences, got %d", rr.Code)
+	}
+
+	silences, err := alert.LoadSilences(filepath.Join(t.TempDir(), "silences.json"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetSilences(silences)
+
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for body, want := range map[string]int{
+		`{"metric":"Heap*","ends_at":"` + endsAt + `","comment":"deploy"}`: http.StatusCreated,
+		`{"metric":"Heap*","ends_at":"2000-01-01T00:00:00Z"}`:              http.StatusBadRequest,
+		`{"metric":"Heap[","ends_at":"` + endsAt + `"}`:                    http.StatusBadRequest,
+		`{"metric":`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/silences", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		h.CreateSilenceHandler(rr, req)
+		if rr.Code != want {
+			t.Fatalf("body %s: expected status %d, got %d (%s)", body, want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.SilencesHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/silences", nil))
+	var resp struct {
+		Silences []alert.Silence `json:"silences"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Silences) != 1 || resp.Silences[0].Metric != "Heap*" || resp.Silences[0].ID == "" {
+		t.Fatalf("unexpected silences: %+v", resp.Silences)
+	}
+
+	id := resp.Silences[0].ID
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rr = httptest.NewRecorder()
+		h.DeleteSilenceHandler(rr, httptest.NewRequest(http.MethodDelete, "/api/v1/silences/"+id, nil))
+		if rr.Code != want {
+			t.Fatalf("delete: expected status %d, got %d", want, rr.Code)
+		}
+	}
+	if list := silences.List(time.Now()); len(list) != 0 {
+		t.Fatalf("expected no silences after delete, got %+v", list)
+	}
+}
+
+func TestHomeHandler_SilencedAlert(t *testing.T) {
+	h, svc := newTestHandler()
+	if err := svc.UpdateMetric("gauge", "HeapAlloc", "90"); err != nil {
+		t.Fatalf("seed gauge: %v", err)
+	}
+	silences := alert.NewSilences()
+	if _, err := silences.Add(alert.Silence{Metric: "HeapAlloc", EndsAt: time.Now().Add(time.Hour)}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	evaluator := alert.NewEvaluator([]alert.Rule{{Name: "HighHeap", Metric: "HeapAlloc", Type: "gauge", Op: ">", Threshold: 50}}, svc)
+	evaluator.SetSilences(silences)
+	evaluator.Evaluate(time.Now())
+	h.SetAlerts(evaluator)
+
+	rr := httptest.NewRecorder()
+	h.HomeHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rr.Body.String(), "<em>silenced</em>") {
+		t.Fatalf("expected silenced marker, body=%q", rr.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/repository"
+	"github.com/xGuthub/metrics-collection-service/internal/service"
+)
+
+func TestSnapshotsHandlers(t *testing.T) {
+	h, svc := newTestHandler()
+	rr := httptest.NewRecorder()
+	h.SnapshotsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/admin/snapshots", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a state file, got %d", rr.Code)
+	}
+
+	store := repository.NewFileStateStore()
+	store.SetRetention(5)
+	svc.SetStateStore(store)
+	svc.ConfigurePersistence(service.PersistenceConfig{
+		FilePath:      filepath.Join(t.TempDir(), "state.json"),
+		StoreInterval: time.Hour,
+	})
+
+	if err := svc.UpdateMetric("counter", "PollCount", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateMetric("counter", "PollCount", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateMetric("gauge", "Alloc", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.SnapshotsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/admin/snapshots", nil))
+	var resp struct {
+		Snapshots []repository.Generation `json:"snapshots"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Snapshots) != 2 {
+		t.Fatalf("expected 2 generations, got %+v", resp.Snapshots)
+	}
+
+	// Restoring the older generation replaces the counter rather than adding to it
+	oldest := resp.Snapshots[1].ID
+	rr = httptest.NewRecorder()
+	h.RestoreSnapshotHandler(rr, httptest.NewRequest(http.MethodPost, "/api/v1/admin/snapshots/"+oldest+"/restore", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if v, err := svc.GetMetric("counter", "PollCount"); err != nil || v != "5" {
+		t.Fatalf("expected restored counter 5, got %q, %v", v, err)
+	}
+	if _, err := svc.GetMetric("gauge", "Alloc"); err == nil {
+		t.Fatalf("gauges written after the generation must be gone")
+	}
+	if gens, _ := svc.SnapshotGenerations(); len(gens) != 3 {
+		t.Fatalf("the restored state must be saved as a new generation, got %+v", gens)
+	}
+
+	for _, path := range []string{"/api/v1/admin/snapshots/20000101T000000.000Z/restore", "/api/v1/admin/snapshots/x"} {
+		rr = httptest.NewRecorder()
+		h.RestoreSnapshotHandler(rr, httptest.NewRequest(http.MethodPost, path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestRestoreSnapshot_GenerationInterval(t *testing.T) {
+	h, svc := newTestHandler()
+	store := repository.NewFileStateStore()
+	store.SetRetention(5)
+	store.SetGenerationInterval(time.Hour)
+	svc.SetStateStore(store)
+	path := filepath.Join(t.TempDir(), "state.json")
+	svc.ConfigurePersistence(service.PersistenceConfig{
+		FilePath:      path,
+		StoreInterval: time.Hour,
+	})
+
+	if err := svc.UpdateMetric("counter", "PollCount", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateMetric("counter", "PollCount", "10"); err != nil {
+		t.Fatal(err)
+	}
+	// Within the interval: the state file is saved, no generation is kept
+	if err := svc.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+	gens, _ := svc.SnapshotGenerations()
+	if len(gens) != 1 {
+		t.Fatalf("expected 1 generation, got %+v", gens)
+	}
+
+	// The restore is kept as the newest generation all the same
+	rr := httptest.NewRecorder()
+	h.RestoreSnapshotHandler(rr, httptest.NewRequest(http.MethodPost, "/api/v1/admin/snapshots/"+gens[0].ID+"/restore", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	after, _ := svc.SnapshotGenerations()
+	if len(after) != 2 || after[1].ID != gens[0].ID {
+		t.Fatalf("expected the restore as a new newest generation, got %+v", after)
+	}
+	state, err := store.LoadGeneration(path, after[0].ID)
+	if err != nil || state.Counters["PollCount"] != 5 {
+		t.Fatalf("expected the restored counter in the new generation, got %v, %v", state.Counters, err)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/repository"
+	"github.com/xGuthub/metrics-collection-service/internal/service"
+)
+
+// gaugeCounterStorage hides the optional capabilities of MemStorage,
+// like a storage keeping gauges and counters only.
+type gaugeCounterStorage struct {
+	service.Storage
+}
+
+func TestUpdate_UnsupportedMetricTypes(t *testing.T) {
+	svc := service.NewMetricsService(gaugeCounterStorage{repository.NewMemStorage()})
+	h := NewMetricsHandler(svc)
+
+	want := []string{models.Histogram, models.Summary, models.Set}
+	if got := svc.UnsupportedMetricTypes(); !slices.Equal(got, want) {
+		t.Fatalf("unsupported types: got %v, want %v", got, want)
+	}
+	if got := service.NewMetricsService(repository.NewMemStorage()).UnsupportedMetricTypes(); len(got) != 0 {
+		t.Fatalf("memory storage supports every type, got %v", got)
+	}
+
+	v := 1.0
+	for _, m := range []models.Metrics{
+		{ID: "latency", MType: models.Histogram, Value: &v},
+		{ID: "rtt", MType: models.Summary, Value: &v},
+		{ID: "users", MType: models.Set, Member: "alice"},
+	} {
+		rr := postJSON(t, h.UpdateJSONHandler, "/update/", m)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "bad metric type") {
+			t.Fatalf("%s: expected %d bad metric type, got %d %q", m.MType, http.StatusBadRequest, rr.Code, rr.Body.String())
+		}
+	}
+
+	g := 2.5
+	if rr := postJSON(t, h.UpdateJSONHandler, "/update/", models.Metrics{ID: "temp", MType: models.Gauge, Value: &g}); rr.Code != http.StatusOK {
+		t.Fatalf("gauge: got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+// failingStorage accepts writes but fails every read, like a database
+// that went away between the two.
+type failingStorage struct {
+	service.Storage
+}
+
+var errStorageDown = errors.New("storage is down")
+
+func (failingStorage) GetGauge(string) (float64, bool, error) { return 0, false, errStorageDown }
+func (failingStorage) GetCounter(string) (int64, bool, error) { return 0, false, errStorageDown }
+func (failingStorage) AllGauges() (map[string]float64, error) { return nil, errStorageDown }
+func (failingStorage) AllCounters() (map[string]int64, error) { return nil, errStorageDown }
+
+func TestReadErrors_InternalError(t *testing.T) {
+	h := NewMetricsHandler(service.NewMetricsService(failingStorage{repository.NewMemStorage()}))
+
+	for name, serve := range map[string]func(w http.ResponseWriter){
+		"value": func(w http.ResponseWriter) {
+			h.ValueHandler(w, httptest.NewRequest(http.MethodGet, "/value/gauge/temp", nil))
+		},
+		"home": func(w http.ResponseWriter) {
+			h.HomeHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		},
+		"prometheus": func(w http.ResponseWriter) {
+			h.PrometheusHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		},
+		"value json": func(w http.ResponseWriter) {
+			rr := postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "hits", MType: models.Counter})
+			w.WriteHeader(rr.Code)
+		},
+		"update json": func(w http.ResponseWriter) {
+			v := 1.0
+			rr := postJSON(t, h.UpdateJSONHandler, "/update/", models.Metrics{ID: "temp", MType: models.Gauge, Value: &v})
+			w.WriteHeader(rr.Code)
+		},
+	} {
+		rr := httptest.NewRecorder()
+		serve(rr)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: expected %d, got %d", name, http.StatusInternalServerError, rr.Code)
+		}
+	}
+}
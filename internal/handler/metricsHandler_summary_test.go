@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+func TestSummary_Quantiles(t *testing.T) {
+	h, _ := newTestHandler()
+
+	for i := 1; i <= 100; i++ {
+		v := float64(i)
+		m := models.Metrics{ID: "latency", MType: models.Summary, Value: &v}
+		if rr := postJSON(t, h.UpdateJSONHandler, "/update/", m); rr.Code != http.StatusOK {
+			t.Fatalf("observe %v: got %d %q", v, rr.Code, rr.Body.String())
+		}
+	}
+
+	for path, want := range map[string]float64{
+		"/value/summary/latency":        50.5,
+		"/value/summary/latency?q=0.99": 99.5,
+		"/value/summary/latency?q=1":    100,
+	} {
+		rr := httptest.NewRecorder()
+		h.ValueHandler(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %q", path, rr.Code, rr.Body.String())
+		}
+		if rr.Body.String() != formatPrometheusFloat(want) {
+			t.Fatalf("%s: got %s, want %v", path, rr.Body.String(), want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ValueHandler(rr, httptest.NewRequest(http.MethodGet, "/value/summary/latency?q=2", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad quantile: expected %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	q := 0.9
+	rr = postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "latency", MType: models.Summary, Quantile: &q})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("value json: got %d %q", rr.Code, rr.Body.String())
+	}
+	var resp models.Metrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Value == nil || math.Abs(*resp.Value-90.5) > 1e-9 || resp.Quantile == nil || *resp.Quantile != 0.9 {
+		t.Fatalf("unexpected value response: %+v", resp)
+	}
+
+	rr = postJSON(t, h.ValueJSONHandler, "/value/", models.Metrics{ID: "missing", MType: models.Summary})
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestSummary_PageAndPrometheus(t *testing.T) {
+	h, svc := newTestHandler()
+	for _, v := range []float64{1, 2, 3} {
+		if err := svc.ObserveSummary("rt", v); err != nil {
+			t.Fatalf("observe: %v", err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.HomeHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rr.Body.String(), "<li>rt: 3 <small>(sum 6, p50 2, p90 3, p99 3)</small></li>") {
+		t.Fatalf("summary row missing from page:\n%s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.PrometheusHandler(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := strings.Join([]string{
+		"# TYPE rt summary",
+		`rt{quantile="0.5"} 2`,
+		`rt{quantile="0.9"} 3`,
+		`rt{quantile="0.99"} 3`,
+		`rt_sum 6`,
+		`rt_count 3`,
+	}, "\n") + "\n"
+	if rr.Body.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", rr.Body.String(), want)
+	}
+}
@@ -118,7 +118,7 @@ func TestUpdateHandler_Success(t *testing.T) {
 	if got := rr.Body.String(); got != "OK" {
 		t.Fatalf("unexpected body: %q", got)
 	}
-	gauges := svc.AllGauges()
+	gauges, _ := svc.AllGauges()
 	if v, ok := gauges["temp"]; !ok || v != 42.5 {
 		t.Fatalf("gauge not updated: got (%v, %v)", v, ok)
 	}
@@ -134,7 +134,7 @@ func TestUpdateHandler_Success(t *testing.T) {
 	if got := rr2.Body.String(); got != "OK" {
 		t.Fatalf("unexpected body: %q", got)
 	}
-	counters := svc.AllCounters()
+	counters, _ := svc.AllCounters()
 	if v, ok := counters["requests"]; !ok || v != 10 {
 		t.Fatalf("counter not updated: got (%v, %v)", v, ok)
 	}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/sign"
 )
 
 func TestUpdateJSONHandler_ContentType(t *testing.T) {
@@ -117,7 +118,7 @@ func TestUpdateJSONHandler_Success_Gauge(t *testing.T) {
 		t.Fatalf("expected delta to be nil, got %v", *resp.Delta)
 	}
 
-	gauges := svc.AllGauges()
+	gauges, _ := svc.AllGauges()
 	if v, ok := gauges["temp"]; !ok || v != 42.5 {
 		t.Fatalf("gauge not updated: got (%v, %v)", v, ok)
 	}
@@ -147,7 +148,7 @@ func TestUpdateJSONHandler_Success_Counter(t *testing.T) {
 		t.Fatalf("expected value to be nil, got %v", *resp.Value)
 	}
 
-	counters := svc.AllCounters()
+	counters, _ := svc.AllCounters()
 	if v, ok := counters["requests"]; !ok || v != 10 {
 		t.Fatalf("counter not updated: got (%v, %v)", v, ok)
 	}
@@ -170,7 +171,57 @@ func TestUpdateJSONHandler_Success_Counter(t *testing.T) {
 	if resp2.Delta == nil || *resp2.Delta != 15 {
 		t.Fatalf("expected accumulated delta 15, got %+v", resp2)
 	}
-	if v := svc.AllCounters()["requests"]; v != 15 {
-		t.Fatalf("expected storage counter 15, got %d", v)
+	if c, _ := svc.AllCounters(); c["requests"] != 15 {
+		t.Fatalf("expected storage counter 15, got %d", c["requests"])
+	}
+}
+
+func TestUpdateJSONHandler_Hash(t *testing.T) {
+	h, svc := newTestHandler()
+	svc.SetSignKey("secret")
+
+	val := 3.5
+	m := models.Metrics{ID: "temp", MType: models.Gauge, Value: &val}
+	m.Hash = sign.Metric("secret", m)
+
+	// Valid hash is accepted and the response is signed
+	buf, _ := json.Marshal(m)
+	req := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBuffer(buf))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.UpdateJSONHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp models.Metrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !sign.VerifyMetric("secret", resp) {
+		t.Fatalf("response hash does not verify: %+v", resp)
+	}
+
+	// Hash made with another key is rejected
+	m.Hash = sign.Metric("other", m)
+	buf2, _ := json.Marshal(m)
+	req2 := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBuffer(buf2))
+	req2.Header.Set("Content-Type", "application/json")
+	rr2 := httptest.NewRecorder()
+	h.UpdateJSONHandler(rr2, req2)
+	if rr2.Code != http.StatusBadRequest || rr2.Body.String() != "hash mismatch" {
+		t.Fatalf("hash mismatch: got %d %q", rr2.Code, rr2.Body.String())
+	}
+	// A metric without a hash is rejected as well
+	m.Hash = ""
+	buf3, _ := json.Marshal(m)
+	req3 := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBuffer(buf3))
+	req3.Header.Set("Content-Type", "application/json")
+	rr3 := httptest.NewRecorder()
+	h.UpdateJSONHandler(rr3, req3)
+	if rr3.Code != http.StatusBadRequest || rr3.Body.String() != "missing hash" {
+		t.Fatalf("missing hash: got %d %q", rr3.Code, rr3.Body.String())
+	}
+	if _, err := svc.GetMetric(models.Gauge, "temp"); err != nil {
+		t.Fatalf("the signed write must be kept: %v", err)
 	}
 }
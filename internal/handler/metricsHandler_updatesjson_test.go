@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/sign"
+)
+
+func TestUpdatesJSONHandler_ContentType_And_BadJSON(t *testing.T) {
+	h, _ := newTestHandler()
+
+	// Wrong content-type
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(`[]`))
+	req.Header.Set("Content-Type", "text/plain")
+	rr := httptest.NewRecorder()
+	h.UpdatesJSONHandler(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, rr.Code)
+	}
+
+	// Not an array
+	req2 := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(`{"id":"a","type":"gauge","value":1}`))
+	req2.Header.Set("Content-Type", "application/json")
+	rr2 := httptest.NewRecorder()
+	h.UpdatesJSONHandler(rr2, req2)
+	if rr2.Code != http.StatusBadRequest || rr2.Body.String() != "bad value" {
+		t.Fatalf("bad json: got %d %q", rr2.Code, rr2.Body.String())
+	}
+
+	// Empty array
+	req3 := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(`[]`))
+	req3.Header.Set("Content-Type", "application/json")
+	rr3 := httptest.NewRecorder()
+	h.UpdatesJSONHandler(rr3, req3)
+	if rr3.Code != http.StatusBadRequest {
+		t.Fatalf("empty batch: expected %d, got %d", http.StatusBadRequest, rr3.Code)
+	}
+}
+
+func TestUpdatesJSONHandler_Success(t *testing.T) {
+	h, svc := newTestHandler()
+
+	v := 1.5
+	d1, d2 := int64(2), int64(3)
+	batch := []models.Metrics{
+		{ID: "temp", MType: models.Gauge, Value: &v},
+		{ID: "hits", MType: models.Counter, Delta: &d1},
+		{ID: "hits", MType: models.Counter, Delta: &d2},
+	}
+	buf, _ := json.Marshal(batch)
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBuffer(buf))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.UpdatesJSONHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+
+	var resp []models.Metrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 items in response, got %d", len(resp))
+	}
+	if resp[1].Delta == nil || *resp[1].Delta != 5 {
+		t.Fatalf("expected accumulated delta 5, got %+v", resp[1])
+	}
+
+	if g, _ := svc.AllGauges(); g["temp"] != 1.5 {
+		t.Fatalf("gauge not updated: got %v", g)
+	}
+	if c, _ := svc.AllCounters(); c["hits"] != 5 {
+		t.Fatalf("counter not updated: got %v", c)
+	}
+}
+
+func TestUpdatesJSONHandler_RejectsWholeBatch(t *testing.T) {
+	h, svc := newTestHandler()
+
+	body := `[
+		{"id":"ok","type":"gauge","value":1},
+		{"id":"c","type":"counter"},
+		{"id":"x","type":"unknown","value":1}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.UpdatesJSONHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	var resp struct {
+		Errors []struct {
+			Index int    `json:"index"`
+			ID    string `json:"id"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Errors) != 2 {
+		t.Fatalf("expected 2 item errors, got %+v", resp.Errors)
+	}
+	if resp.Errors[0].Index != 1 || resp.Errors[0].Error != "bad value" {
+		t.Fatalf("unexpected first error: %+v", resp.Errors[0])
+	}
+	if resp.Errors[1].Index != 2 || resp.Errors[1].Error != "bad metric type" {
+		t.Fatalf("unexpected second error: %+v", resp.Errors[1])
+	}
+
+	// Nothing from the batch must be applied
+	gauges, _ := svc.AllGauges()
+	if _, ok := gauges["ok"]; ok {
+		t.Fatalf("valid element of a rejected batch was applied")
+	}
+}
+
+func TestUpdatesJSONHandler_UnsignedMetric(t *testing.T) {
+	h, svc := newTestHandler()
+	svc.SetSignKey("secret")
+
+	v := 1.0
+	signed := models.Metrics{ID: "a", MType: models.Gauge, Value: &v}
+	signed.Hash = sign.Metric("secret", signed)
+	d := int64(5)
+	unsigned := models.Metrics{ID: "c", MType: models.Counter, Delta: &d}
+
+	buf, _ := json.Marshal([]models.Metrics{signed, unsigned})
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBuffer(buf))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.UpdatesJSONHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	var resp struct {
+		Errors []struct {
+			Index int    `json:"index"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Index != 1 || resp.Errors[0].Error != "missing hash" {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+	counters, _ := svc.AllCounters()
+	if _, ok := counters["c"]; ok {
+		t.Fatalf("unsigned counter was applied")
+	}
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+)
+
+// prometheusContentType is the content type of the text exposition format 0.0.4.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promFamily is a metric family: one "# TYPE" line followed by its samples.
+type promFamily struct {
+	name    string
+	mType   string
+	samples []string
+}
+
+// PrometheusHandler renders all gauges, counters, histograms and summaries
+// in Prometheus text format. Set estimates are exposed as gauges.
+func (mh *MetricsHandler) PrometheusHandler(w http.ResponseWriter, _ *http.Request) {
+	gauges, err := mh.metricsService.AllGauges()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+	counters, err := mh.metricsService.AllCounters()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+	histograms, err := mh.metricsService.AllHistograms()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+	summaries, err := mh.metricsService.SummarySnapshots()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+	sets, err := mh.metricsService.AllSetCardinalities()
+	if err != nil {
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+
+	var families []*promFamily
+	byName := make(map[string]*promFamily)
+
+	// render returns the sample lines of one series
+	add := func(key, mType string, render func(name string, labels map[string]string) []string) {
+		name, labels, err := models.ParseSeriesKey(key)
+		if err != nil {
+			return
+		}
+		promName := sanitizePrometheusName(name)
+		fam, ok := byName[promName]
+		if !ok {
+			fam = &promFamily{name: promName, mType: mType}
+			byName[promName] = fam
+			families = append(families, fam)
+		}
+		// Two source names may sanitize into the same family of another type;
+		// keep the first one, mixed families make the whole exposition invalid.
+		if fam.mType != mType {
+			return
+		}
+		fam.samples = append(fam.samples, render(promName, labels)...)
+	}
+	// Label names are validated on write, SeriesKey renders them in exposition syntax
+	single := func(value string) func(string, map[string]string) []string {
+		return func(name string, labels map[string]string) []string {
+			return []string{name + models.SeriesKey("", labels) + " " + value}
+		}
+	}
+
+	for _, key := range sortedKeys(gauges) {
+		add(key, "gauge", single(formatPrometheusFloat(gauges[key])))
+	}
+	for _, key := range sortedKeys(sets) {
+		// A gauge of the same series already owns the sample
+		if _, ok := gauges[key]; ok {
+			continue
+		}
+		add(key, "gauge", single(strconv.FormatUint(sets[key], 10)))
+	}
+	for _, key := range sortedKeys(counters) {
+		add(key, "counter", single(strconv.FormatInt(counters[key], 10)))
+	}
+	for _, key := range sortedKeys(histograms) {
+		h := histograms[key]
+		add(key, "histogram", func(name string, labels map[string]string) []string {
+			return histogramSamples(name, labels, h)
+		})
+	}
+	for _, key := range sortedKeys(summaries) {
+		td := summaries[key]
+		add(key, "summary", func(name string, labels map[string]string) []string {
+			return summarySamples(name, labels, td)
+		})
+	}
+
+	var sb strings.Builder
+	for _, fam := range families {
+		sb.WriteString("# TYPE " + fam.name + " " + fam.mType + "\n")
+		for _, sample := range fam.samples {
+			sb.WriteString(sample + "\n")
+		}
+	}
+
+	w.Header().Set("Content-Type", prometheusContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+// histogramSamples renders cumulative _bucket samples followed by _sum and _count.
+// A user label named "le" is replaced by the bucket bound.
+func histogramSamples(name string, labels map[string]string, h *sketch.Histogram) []string {
+	bucketLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		bucketLabels[k] = v
+	}
+
+	out := make([]string, 0, len(h.Counts)+2)
+	var cumulative uint64
+	for i, c := range h.Counts {
+		cumulative += c
+		bucketLabels["le"] = "+Inf"
+		if i < len(h.Bounds) {
+			bucketLabels["le"] = formatPrometheusFloat(h.Bounds[i])
+		}
+		out = append(out, name+"_bucket"+models.SeriesKey("", bucketLabels)+" "+strconv.FormatUint(cumulative, 10))
+	}
+	out = append(out,
+		name+"_sum"+models.SeriesKey("", labels)+" "+formatPrometheusFloat(h.Sum),
+		name+"_count"+models.SeriesKey("", labels)+" "+strconv.FormatUint(h.Count, 10),
+	)
+
+	return out
+}
+
+// summarySamples renders quantile samples over the current window followed by _sum and _count.
+// A user label named "quantile" is replaced.
+func summarySamples(name string, labels map[string]string, td *sketch.TDigest) []string {
+	quantileLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		quantileLabels[k] = v
+	}
+
+	out := make([]string, 0, len(summaryQuantiles)+2)
+	for _, q := range summaryQuantiles {
+		quantileLabels["quantile"] = formatPrometheusFloat(q)
+		// An empty window yields NaN, as Prometheus client libraries report it
+		out = append(out, name+models.SeriesKey("", quantileLabels)+" "+formatPrometheusFloat(td.Quantile(q)))
+	}
+	out = append(out,
+		name+"_sum"+models.SeriesKey("", labels)+" "+formatPrometheusFloat(td.Sum),
+		name+"_count"+models.SeriesKey("", labels)+" "+formatPrometheusFloat(td.Count),
+	)
+
+	return out
+}
+
+// sanitizePrometheusName maps an arbitrary metric name to [a-zA-Z_:][a-zA-Z0-9_:]*.
+func sanitizePrometheusName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || c == ':' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	// Keep the leading digit visible instead of losing it to '_'
+	if name[0] >= '0' && name[0] <= '9' {
+		return "_" + name[:1] + string(b[1:])
+	}
+
+	return string(b)
+}
+
+func formatPrometheusFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/alert"
+)
+
+// SilenceStore keeps alert silences.
+type SilenceStore interface {
+	Add(s alert.Silence, now time.Time) (alert.Silence, error)
+	Delete(id string, now time.Time) error
+	List(now time.Time) []alert.Silence
+}
+
+// SetSilences enables the silences API.
+func (mh *MetricsHandler) SetSilences(silences SilenceStore) {
+	mh.silences = silences
+}
+
+// SilencesHandler serves GET /api/v1/silences: the active silences.
+func (mh *MetricsHandler) SilencesHandler(w http.ResponseWriter, _ *http.Request) {
+	if mh.silences == nil {
+		writePlain(w, http.StatusNotFound, "silences disabled")
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"silences": mh.silences.List(time.Now())})
+}
+
+// CreateSilenceHandler serves POST /api/v1/silences. The body is a silence
+// with at least "metric" and "ends_at" (RFC 3339); the stored silence with
+// its ID is returned.
+func (mh *MetricsHandler) CreateSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	if mh.silences == nil {
+		writePlain(w, http.StatusNotFound, "silences disabled")
+
+		return
+	}
+	if err := validateContentType(r, "application/json"); err != nil {
+		writePlain(w, http.StatusUnsupportedMediaType, "unsupported media type: expected application/json")
+
+		return
+	}
+
+	var s alert.Silence
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		writePlain(w, http.StatusBadRequest, "bad value")
+
+		return
+	}
+	created, err := mh.silences.Add(s, time.Now())
+	if err != nil {
+		if errors.Is(err, alert.ErrBadSilence) {
+			writePlain(w, http.StatusBadRequest, "bad value")
+
+			return
+		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// DeleteSilenceHandler serves DELETE /api/v1/silences/{id}.
+func (mh *MetricsHandler) DeleteSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	if mh.silences == nil {
+		writePlain(w, http.StatusNotFound, "silences disabled")
+
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/silences/")
+	if err := mh.silences.Delete(id, time.Now()); err != nil {
+		if errors.Is(err, alert.ErrSilenceNotFound) {
+			writePlain(w, http.StatusNotFound, "not found")
+
+			return
+		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SnapshotsHandler serves GET /api/v1/admin/snapshots: the kept generations
+// of the state file, newest first.
+func (mh *MetricsHandler) SnapshotsHandler(w http.ResponseWriter, _ *http.Request) {
+	gens, err := mh.metricsService.SnapshotGenerations()
+	if err != nil {
+		if err.Error() == "snapshots disabled" {
+			writePlain(w, http.StatusNotFound, "snapshots disabled")
+
+			return
+		}
+		writePlain(w, http.StatusInternalServerError, "internal error")
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"snapshots": gens})
+}
+
+// RestoreSnapshotHandler serves POST /api/v1/admin/snapshots/{id}/restore:
+// replaces all metrics of the running server with the chosen generation.
+func (mh *MetricsHandler) RestoreSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/snapshots/")
+	id, ok := strings.CutSuffix(rest, "/restore")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		writePlain(w, http.StatusNotFound, "not found")
+
+		return
+	}
+
+	if err := mh.metricsService.RestoreGeneration(id); err != nil {
+		switch err.Error() {
+		case "snapshots disabled":
+			writePlain(w, http.StatusNotFound, "snapshots disabled")
+		case "not found":
+			writePlain(w, http.StatusNotFound, "not found")
+		case "corrupt snapshot":
+			writePlain(w, http.StatusUnprocessableEntity, "corrupt snapshot")
+		default:
+			writePlain(w, http.StatusInternalServerError, "internal error")
+		}
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"restored": id})
+}
@@ -1,8 +1,13 @@
 package models
 
+import "github.com/xGuthub/metrics-collection-service/internal/sketch"
+
 const (
-	Counter = "counter"
-	Gauge   = "gauge"
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+	Summary   = "summary"
+	Set       = "set"
 )
 
 // NOTE: Не усложняем пример, вводя иерархическую вложенность структур.
@@ -16,4 +21,16 @@ type Metrics struct {
 	Delta *int64   `json:"delta,omitempty"`
 	Value *float64 `json:"value,omitempty"`
 	Hash  string   `json:"hash,omitempty"`
+	// Labels are optional; together with ID they identify a series.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Buckets are the upper bounds of a histogram, declared on its first write;
+	// Value carries the observation.
+	Buckets []float64 `json:"buckets,omitempty"`
+	// Histogram is the accumulated state of a histogram in responses.
+	Histogram *sketch.Histogram `json:"histogram,omitempty"`
+	// Quantile selects the summary quantile returned in Value, 0.5 when not set.
+	Quantile *float64 `json:"quantile,omitempty"`
+	// Member is the value added to a set; responses carry the estimated
+	// number of distinct members in Delta.
+	Member string `json:"member,omitempty"`
 }
@@ -0,0 +1,8 @@
+package models
+
+// Point is a timestamped sample of a metric value.
+type Point struct {
+	// Timestamp is in Unix milliseconds.
+	Timestamp int64   `json:"t"`
+	Value     float64 `json:"v"`
+}
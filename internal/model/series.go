@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Series identity is the metric name plus its sorted labels, encoded as a key
+// in Prometheus notation: name{a="1",b="2"}. Unlabeled series are keyed by
+// the bare name, so they look exactly like before labels were introduced.
+
+// Key returns the series key of the metric.
+func (m Metrics) Key() string {
+	return SeriesKey(m.ID, m.Labels)
+}
+
+// SeriesKey builds the canonical series key for name and labels.
+func SeriesKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(labelValueEscaper.Replace(labels[k]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var errBadSeriesKey = errors.New("malformed series key")
+
+// ParseSeriesKey splits a series key into the metric name and its labels.
+// Labels are nil for unlabeled series.
+func ParseSeriesKey(key string) (string, map[string]string, error) {
+	i := strings.IndexByte(key, '{')
+	if i < 0 {
+		return key, nil, nil
+	}
+	if !strings.HasSuffix(key, "}") {
+		return "", nil, errBadSeriesKey
+	}
+	name, rest := key[:i], key[i+1:len(key)-1]
+
+	labels := make(map[string]string)
+	for rest != "" {
+		eq := strings.Index(rest, `="`)
+		if eq <= 0 {
+			return "", nil, errBadSeriesKey
+		}
+		label := rest[:eq]
+		rest = rest[eq+2:]
+
+		var val strings.Builder
+		closed := false
+		for j := 0; j < len(rest); j++ {
+			c := rest[j]
+			if c == '\\' && j+1 < len(rest) {
+				j++
+				switch rest[j] {
+				case 'n':
+					val.WriteByte('\n')
+				default:
+					val.WriteByte(rest[j])
+				}
+
+				continue
+			}
+			if c == '"' {
+				rest = rest[j+1:]
+				closed = true
+
+				break
+			}
+			val.WriteByte(c)
+		}
+		if !closed {
+			return "", nil, errBadSeriesKey
+		}
+		labels[label] = val.String()
+
+		if rest != "" {
+			if rest[0] != ',' {
+				return "", nil, errBadSeriesKey
+			}
+			rest = rest[1:]
+		}
+	}
+
+	return name, labels, nil
+}
+
+// ValidLabelName reports whether s is a valid label name: [a-zA-Z_][a-zA-Z0-9_]*.
+func ValidLabelName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeriesKey(t *testing.T) {
+	if got := SeriesKey("Alloc", nil); got != "Alloc" {
+		t.Fatalf("unlabeled key: got %q", got)
+	}
+
+	got := SeriesKey("Alloc", map[string]string{"service": "web", "host": "web01"})
+	if want := `Alloc{host="web01",service="web"}`; got != want {
+		t.Fatalf("labels must be sorted: got %q, want %q", got, want)
+	}
+
+	got = SeriesKey("x", map[string]string{"v": "a\"b\\c\nd"})
+	if want := `x{v="a\"b\\c\nd"}`; got != want {
+		t.Fatalf("values must be escaped: got %q, want %q", got, want)
+	}
+}
+
+func TestParseSeriesKey(t *testing.T) {
+	labels := map[string]string{"host": "web01", "path": `/a,b="c"`, "nl": "x\ny"}
+	key := SeriesKey("requests", labels)
+
+	name, got, err := ParseSeriesKey(key)
+	if err != nil {
+		t.Fatalf("parse %q: %v", key, err)
+	}
+	if name != "requests" || !reflect.DeepEqual(got, labels) {
+		t.Fatalf("round trip: got %q %v", name, got)
+	}
+
+	name, got, err = ParseSeriesKey("Alloc")
+	if err != nil || name != "Alloc" || got != nil {
+		t.Fatalf("unlabeled: got %q %v %v", name, got, err)
+	}
+
+	for _, bad := range []string{`a{`, `a{b}`, `a{b="c}`, `a{b="c"d="e"}`} {
+		if _, _, err := ParseSeriesKey(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestValidLabelName(t *testing.T) {
+	for _, ok := range []string{"host", "_x", "a1"} {
+		if !ValidLabelName(ok) {
+			t.Fatalf("%q must be valid", ok)
+		}
+	}
+	for _, bad := range []string{"", "1a", "a-b", "a b"} {
+		if ValidLabelName(bad) {
+			t.Fatalf("%q must be invalid", bad)
+		}
+	}
+}
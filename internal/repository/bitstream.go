@@ -0,0 +1,70 @@
+package repository
+
+import "io"
+
+// bitWriter appends bits MSB first.
+type bitWriter struct {
+	buf []byte
+	// free is the number of unused low bits in the last byte.
+	free uint8
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.free == 0 {
+		w.buf = append(w.buf, 0)
+		w.free = 8
+	}
+	w.free--
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << w.free
+	}
+}
+
+// writeBits writes the n low bits of u.
+func (w *bitWriter) writeBits(u uint64, n int) {
+	for n > 0 {
+		if w.free == 0 {
+			w.buf = append(w.buf, 0)
+			w.free = 8
+		}
+		// Fill as many bits of the current byte as possible at once
+		k := min(n, int(w.free))
+		chunk := byte(u>>(n-k)) & (1<<k - 1)
+		w.free -= uint8(k)
+		w.buf[len(w.buf)-1] |= chunk << w.free
+		n -= k
+	}
+}
+
+// bitReader reads bits written by bitWriter.
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= len(r.buf)*8 {
+		return false, io.ErrUnexpectedEOF
+	}
+	bit := r.buf[r.pos/8]&(1<<(7-r.pos%8)) != 0
+	r.pos++
+
+	return bit, nil
+}
+
+func (r *bitReader) readBits(n int) (uint64, error) {
+	if r.pos+n > len(r.buf)*8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var u uint64
+	for n > 0 {
+		avail := 8 - r.pos%8
+		k := min(n, avail)
+		b := r.buf[r.pos/8] >> (avail - k) & (1<<k - 1)
+		u = u<<k | uint64(b)
+		r.pos += k
+		n -= k
+	}
+
+	return u, nil
+}
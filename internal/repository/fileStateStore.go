@@ -2,35 +2,111 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
 )
 
+// State is a copy of all metrics.
+// Maps are keyed by series key (see models.SeriesKey).
+type State struct {
+	Gauges     map[string]float64
+	Counters   map[string]int64
+	Histograms map[string]*sketch.Histogram
+	Summaries  map[string]*sketch.Summary
+	Sets       map[string]*sketch.HyperLogLog
+}
+
 // StateStore defines persistence for metrics state.
 // It is intentionally storage-agnostic (accepts plain maps).
 type StateStore interface {
-	Save(path string, gauges map[string]float64, counters map[string]int64) error
-	Load(path string) (gauges map[string]float64, counters map[string]int64, err error)
+	Save(path string, state State) error
+	// Load returns a State with all maps initialized.
+	Load(path string) (State, error)
+}
+
+// FileStateStore persists metrics state into a snapshot file: a header line
+// with the format version, creation time and a SHA-256 checksum, followed by
+// the JSON state, optionally compressed. The snapshot it replaces is kept as
+// <path>.prev, and with a retention set every snapshot is also kept as a
+// timestamped generation, at most one per generation interval. Load falls back to the newest good one of them
+// when the current snapshot is corrupt.
+type FileStateStore struct {
+	// mu serializes saves, which share the temporary files
+	mu          sync.Mutex
+	compression Compression
+	retention   int
+	genInterval time.Duration
+	// lastGen is the creation time of the newest generation per state file
+	lastGen map[string]time.Time
+	onError func(error)
 }
 
-// FileStateStore persists metrics state into a JSON file.
-type FileStateStore struct{}
+func NewFileStateStore() *FileStateStore {
+	return &FileStateStore{compression: CompressionNone, lastGen: make(map[string]time.Time)}
+}
 
-func NewFileStateStore() *FileStateStore { return &FileStateStore{} }
+// SetCompression selects the body encoding of new snapshots.
+// Load detects the encoding itself, so it can change between runs.
+func (f *FileStateStore) SetCompression(c Compression) {
+	f.compression = c
+}
+
+// SetErrorHandler sets a callback for problems Load recovered from,
+// such as a corrupt snapshot replaced by the previous one.
+func (f *FileStateStore) SetErrorHandler(onError func(error)) {
+	f.onError = onError
+}
 
 type stateDump struct {
-	Gauges   map[string]float64 `json:"gauges"`
-	Counters map[string]int64   `json:"counters"`
+	Gauges     map[string]float64             `json:"gauges"`
+	Counters   map[string]int64               `json:"counters"`
+	Histograms map[string]*sketch.Histogram   `json:"histograms,omitempty"`
+	Summaries  map[string]*sketch.Summary     `json:"summaries,omitempty"`
+	Sets       map[string]*sketch.HyperLogLog `json:"sets,omitempty"`
+}
+
+func (f *FileStateStore) Save(path string, state State) error {
+	return f.save(path, state, false)
+}
+
+// SaveGeneration saves state like Save and always keeps it as a generation,
+// whatever the time since the last one.
+func (f *FileStateStore) SaveGeneration(path string, state State) error {
+	return f.save(path, state, true)
 }
 
-func (f *FileStateStore) Save(path string, gauges map[string]float64, counters map[string]int64) error {
+func (f *FileStateStore) save(path string, state State, forceGen bool) error {
 	if path == "" {
 		return nil
 	}
 
-	dump := stateDump{Gauges: gauges, Counters: counters}
+	dump := stateDump{
+		Gauges:     state.Gauges,
+		Counters:   state.Counters,
+		Histograms: state.Histograms,
+		Summaries:  state.Summaries,
+		Sets:       state.Sets,
+	}
 
-	data, err := json.MarshalIndent(dump, "", "  ")
+	var body []byte
+	var err error
+	if f.compression == CompressionNone || f.compression == "" {
+		body, err = json.MarshalIndent(dump, "", "  ")
+	} else {
+		body, err = json.Marshal(dump)
+	}
+	if err != nil {
+		return err
+	}
+	created := time.Now()
+	data, err := encodeSnapshot(body, f.compression, created)
 	if err != nil {
 		return err
 	}
@@ -42,33 +118,190 @@ func (f *FileStateStore) Save(path string, gauges map[string]float64, counters m
 		}
 	}
 
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := keepPrev(path); err != nil {
 		return err
 	}
-	return os.Rename(tmp, path)
+	if err := renameFile(tmp, path); err != nil {
+		return err
+	}
+	if f.retention > 0 && (forceGen || f.generationDue(path, created)) {
+		return f.keepGeneration(path, data, created)
+	}
+	return nil
 }
 
-func (f *FileStateStore) Load(path string) (map[string]float64, map[string]int64, error) {
+func (f *FileStateStore) Load(path string) (State, error) {
 	if path == "" {
-		return map[string]float64{}, map[string]int64{}, nil
+		return emptyState(), nil
+	}
+	state, err := loadSnapshot(path)
+	if err == nil || !errors.Is(err, ErrCorruptSnapshot) {
+		return state, err
+	}
+
+	for _, candidate := range f.fallbacks(path) {
+		state, candErr := loadSnapshot(candidate)
+		if candErr != nil {
+			continue
+		}
+		if f.onError != nil {
+			f.onError(fmt.Errorf("snapshot %s: %w; restored %s", path, err, candidate))
+		}
+
+		return state, nil
+	}
+
+	return State{}, fmt.Errorf("snapshot %s: %w; no good snapshot to fall back to", path, err)
+}
+
+// fallbacks returns the previous snapshot and the generations of path,
+// newest first. The newest generation is often a copy of the snapshot at
+// path itself, so it comes before an older previous snapshot.
+func (f *FileStateStore) fallbacks(path string) []string {
+	type candidate struct {
+		path    string
+		created time.Time
+	}
+	var candidates []candidate
+	// The previous snapshot is a link to the file as it was written
+	if info, err := os.Stat(prevPath(path)); err == nil {
+		candidates = append(candidates, candidate{prevPath(path), info.ModTime()})
+	}
+	gens, _ := f.Generations(path)
+	for _, g := range gens {
+		candidates = append(candidates, candidate{generationPath(path, g.ID), g.Created})
 	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].created.After(candidates[j].created) })
+
+	paths := make([]string, len(candidates))
+	for i, c := range candidates {
+		paths[i] = c.path
+	}
+	return paths
+}
+
+// loadSnapshot reads and verifies one snapshot file; a missing file is an empty state.
+func loadSnapshot(path string) (State, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return map[string]float64{}, map[string]int64{}, nil
+			return emptyState(), nil
 		}
-		return nil, nil, err
+		return State{}, err
+	}
+	body, err := decodeSnapshot(data)
+	if err != nil {
+		return State{}, err
 	}
 	var dump stateDump
-	if err := json.Unmarshal(data, &dump); err != nil {
-		return nil, nil, err
+	if err := json.Unmarshal(body, &dump); err != nil {
+		return State{}, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	state := emptyState()
+	if dump.Gauges != nil {
+		state.Gauges = dump.Gauges
+	}
+	if dump.Counters != nil {
+		state.Counters = dump.Counters
+	}
+	for name, h := range dump.Histograms {
+		if h == nil {
+			continue
+		}
+		if err := h.Validate(); err != nil {
+			return State{}, fmt.Errorf("%w: histogram %q: %v", ErrCorruptSnapshot, name, err)
+		}
+		state.Histograms[name] = h
+	}
+	for name, sm := range dump.Summaries {
+		if sm == nil {
+			continue
+		}
+		if err := sm.Validate(); err != nil {
+			return State{}, fmt.Errorf("%w: summary %q: %v", ErrCorruptSnapshot, name, err)
+		}
+		state.Summaries[name] = sm
+	}
+	for name, hll := range dump.Sets {
+		if hll == nil {
+			continue
+		}
+		if err := hll.Validate(); err != nil {
+			return State{}, fmt.Errorf("%w: set %q: %v", ErrCorruptSnapshot, name, err)
+		}
+		state.Sets[name] = hll
 	}
-	if dump.Gauges == nil {
-		dump.Gauges = map[string]float64{}
+	return state, nil
+}
+
+func prevPath(path string) string {
+	return path + ".prev"
+}
+
+// renameFile moves a written snapshot into place; tests replace it to
+// simulate a crash before that.
+var renameFile = os.Rename
+
+// linkFile hard-links the snapshot to keep as the previous one; tests
+// replace it to simulate file systems without hard links.
+var linkFile = os.Link
+
+// keepPrev makes the snapshot at path the previous one. It is linked rather
+// than moved, so a snapshot stays at path until the new one replaces it.
+// Where links are not supported the snapshot is copied instead.
+func keepPrev(path string) error {
+	prev := prevPath(path)
+	if err := os.Remove(prev + ".tmp"); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	if dump.Counters == nil {
-		dump.Counters = map[string]int64{}
+	if err := linkFile(path, prev+".tmp"); err != nil {
+		if os.IsNotExist(err) {
+			// The first snapshot has no predecessor
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeFileSync(prev+".tmp", data); err != nil {
+			return err
+		}
+	}
+	return os.Rename(prev+".tmp", prev)
+}
+
+// writeFileSync writes data and flushes it to disk before the file is renamed into place.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func emptyState() State {
+	return State{
+		Gauges:     map[string]float64{},
+		Counters:   map[string]int64{},
+		Histograms: map[string]*sketch.Histogram{},
+		Summaries:  map[string]*sketch.Summary{},
+		Sets:       map[string]*sketch.HyperLogLog{},
 	}
-	return dump.Gauges, dump.Counters, nil
 }
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+)
+
+func TestFileStateStore_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore()
+
+	h, err := sketch.NewHistogram([]float64{1, 5})
+	if err != nil {
+		t.Fatalf("new histogram: %v", err)
+	}
+	h.Observe(3)
+	h.Observe(7)
+
+	sm, err := sketch.NewSummary(time.Minute, 0)
+	if err != nil {
+		t.Fatalf("new summary: %v", err)
+	}
+	now := time.Now()
+	for _, v := range []float64{1, 2, 3} {
+		sm.Observe(v, now)
+	}
+
+	hll, err := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+	if err != nil {
+		t.Fatalf("new hyperloglog: %v", err)
+	}
+	for _, member := range []string{"a", "b", "c", "a"} {
+		hll.Add(member)
+	}
+
+	in := State{
+		Gauges:     map[string]float64{"Alloc": 1.5},
+		Counters:   map[string]int64{`PollCount{host="a"}`: 3},
+		Histograms: map[string]*sketch.Histogram{"latency": h},
+		Summaries:  map[string]*sketch.Summary{"rt": sm},
+		Sets:       map[string]*sketch.HyperLogLog{"users": hll},
+	}
+	if err := store.Save(path, in); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	out, err := store.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if out.Gauges["Alloc"] != 1.5 || out.Counters[`PollCount{host="a"}`] != 3 {
+		t.Fatalf("unexpected state: %+v", out)
+	}
+	got := out.Histograms["latency"]
+	if got == nil || got.Count != 2 || got.Sum != 10 || got.Counts[1] != 1 || got.Counts[2] != 1 {
+		t.Fatalf("unexpected histogram: %+v", got)
+	}
+	rt := out.Summaries["rt"]
+	if rt == nil || rt.MaxAge != time.Minute {
+		t.Fatalf("unexpected summary: %+v", rt)
+	}
+	if med := rt.Snapshot(now).Quantile(0.5); med != 2 {
+		t.Fatalf("restored summary median: got %v", med)
+	}
+	if users := out.Sets["users"]; users == nil || users.Estimate() != 3 {
+		t.Fatalf("unexpected set: %+v", users)
+	}
+}
+
+func TestFileStateStore_Load(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStateStore()
+
+	// A missing file is an empty state
+	state, err := store.Load(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("load missing: %v", err)
+	}
+	if state.Gauges == nil || state.Counters == nil || state.Histograms == nil || state.Summaries == nil || state.Sets == nil {
+		t.Fatalf("maps must be initialized: %+v", state)
+	}
+
+	// Dumps written before histograms existed still load
+	old := filepath.Join(dir, "old.json")
+	if err := os.WriteFile(old, []byte(`{"gauges":{"a":1},"counters":{"b":2}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, err = store.Load(old)
+	if err != nil || state.Gauges["a"] != 1 || state.Counters["b"] != 2 {
+		t.Fatalf("load old dump: %+v, %v", state, err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	dump := `{"histograms":{"h":{"bounds":[1],"counts":[1],"sum":1,"count":1}}}`
+	if err := os.WriteFile(broken, []byte(dump), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(broken); err == nil {
+		t.Fatalf("expected error for inconsistent histogram")
+	}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// generationLayout is the timestamp suffix of a snapshot generation.
+// It doubles as the generation ID and sorts in time order.
+const generationLayout = "20060102T150405.000Z"
+
+// ErrGenerationNotFound is returned for an unknown generation ID.
+var ErrGenerationNotFound = errors.New("not found")
+
+// Generation is a point-in-time copy of the state file,
+// kept as <path>.<ID> next to it.
+type Generation struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// SetRetention keeps the last n snapshots as timestamped generations;
+// 0 keeps none.
+func (f *FileStateStore) SetRetention(n int) {
+	f.retention = n
+}
+
+// SetGenerationInterval keeps at most one generation per interval d, so that
+// frequent saves do not turn the retention into the last few writes;
+// 0 keeps every snapshot.
+func (f *FileStateStore) SetGenerationInterval(d time.Duration) {
+	f.genInterval = d
+}
+
+// Generations lists the generations of the state file at path, newest first.
+func (f *FileStateStore) Generations(path string) ([]Generation, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prefix := filepath.Base(path) + "."
+	var gens []Generation
+	for _, e := range entries {
+		id, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		// .tmp, .prev and foreign files do not parse as a timestamp
+		created, err := time.Parse(generationLayout, id)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		gens = append(gens, Generation{ID: id, Created: created, Size: info.Size()})
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i].Created.After(gens[j].Created) })
+
+	return gens, nil
+}
+
+// LoadGeneration reads and verifies one generation of the state file at path.
+func (f *FileStateStore) LoadGeneration(path, id string) (State, error) {
+	// Only well-formed IDs are turned into file names
+	if _, err := time.Parse(generationLayout, id); err != nil {
+		return State{}, ErrGenerationNotFound
+	}
+	genPath := generationPath(path, id)
+	if _, err := os.Stat(genPath); err != nil {
+		if os.IsNotExist(err) {
+			return State{}, ErrGenerationNotFound
+		}
+		return State{}, err
+	}
+
+	return loadSnapshot(genPath)
+}
+
+// keepGeneration records the snapshot just written to path as a generation
+// and removes the ones beyond the retention. Generations are separate copies
+// rather than hard links, so damage to the state file cannot reach them.
+func (f *FileStateStore) keepGeneration(path string, data []byte, created time.Time) error {
+	// IDs have millisecond resolution: a generation saved within the same
+	// millisecond as another takes the next free one instead of replacing it
+	genPath := generationPath(path, created.UTC().Format(generationLayout))
+	for {
+		_, err := os.Stat(genPath)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		created = created.Add(time.Millisecond)
+		genPath = generationPath(path, created.UTC().Format(generationLayout))
+	}
+	f.lastGen[path] = created
+	if err := writeFileSync(genPath+".tmp", data); err != nil {
+		return err
+	}
+	if err := os.Rename(genPath+".tmp", genPath); err != nil {
+		return err
+	}
+
+	gens, err := f.Generations(path)
+	if err != nil {
+		return err
+	}
+	for _, g := range gens[min(len(gens), f.retention):] {
+		if err := os.Remove(generationPath(path, g.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// generationDue reports whether a snapshot created at the given time is to be
+// kept as a generation. Called with mu held.
+func (f *FileStateStore) generationDue(path string, created time.Time) bool {
+	if f.genInterval <= 0 {
+		return true
+	}
+	last, ok := f.lastGen[path]
+	if !ok {
+		// Generations kept before a restart count too
+		if gens, err := f.Generations(path); err == nil && len(gens) > 0 {
+			last = gens[0].Created
+		}
+		f.lastGen[path] = last
+	}
+	return created.Sub(last) >= f.genInterval
+}
+
+func generationPath(path, id string) string {
+	return path + "." + id
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStateStore_Generations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore()
+	store.SetRetention(3)
+
+	for i := 1; i <= 5; i++ {
+		if err := store.Save(path, testState(i)); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+
+	gens, err := store.Generations(path)
+	if err != nil {
+		t.Fatalf("generations: %v", err)
+	}
+	if len(gens) != 3 {
+		t.Fatalf("expected 3 generations, got %+v", gens)
+	}
+	if !gens[0].Created.After(gens[1].Created) || gens[2].Size == 0 {
+		t.Fatalf("expected newest first with sizes, got %+v", gens)
+	}
+
+	oldest, err := store.LoadGeneration(path, gens[2].ID)
+	if err != nil {
+		t.Fatalf("load generation: %v", err)
+	}
+	if len(oldest.Gauges) != 3 {
+		t.Fatalf("expected the third save, got %d gauges", len(oldest.Gauges))
+	}
+	for _, id := range []string{"20000101T000000.000Z", "../state.json", ""} {
+		if _, err := store.LoadGeneration(path, id); !errors.Is(err, ErrGenerationNotFound) {
+			t.Fatalf("id %q: expected not found, got %v", id, err)
+		}
+	}
+
+	// A corrupt snapshot is replaced by the newest generation, its own copy,
+	// rather than by the older previous snapshot
+	if err := os.WriteFile(path, []byte(`{"gauges":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := store.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(state.Gauges) != 5 {
+		t.Fatalf("expected the last save from the newest generation, got %d gauges", len(state.Gauges))
+	}
+
+	// With the snapshot and its predecessor corrupt, the newest good generation is used
+	for _, p := range []string{path, prevPath(path), generationPath(path, gens[0].ID)} {
+		if err := os.WriteFile(p, []byte(`{"gauges":`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var recovered error
+	store.SetErrorHandler(func(err error) { recovered = err })
+	state, err = store.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(state.Gauges) != 4 || recovered == nil {
+		t.Fatalf("expected the fourth save from a generation, got %d gauges, %v", len(state.Gauges), recovered)
+	}
+}
+
+func TestFileStateStore_GenerationInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore()
+	store.SetRetention(10)
+	store.SetGenerationInterval(time.Hour)
+
+	// Saves on every write keep one generation per interval, not one per save
+	for i := 1; i <= 5; i++ {
+		if err := store.Save(path, testState(i)); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+	gens, err := store.Generations(path)
+	if err != nil {
+		t.Fatalf("generations: %v", err)
+	}
+	if len(gens) != 1 {
+		t.Fatalf("expected 1 generation, got %+v", gens)
+	}
+
+	// The generations on disk count after a restart as well
+	restarted := NewFileStateStore()
+	restarted.SetRetention(10)
+	restarted.SetGenerationInterval(time.Hour)
+	if err := restarted.Save(path, testState(6)); err != nil {
+		t.Fatal(err)
+	}
+	if gens, _ := restarted.Generations(path); len(gens) != 1 {
+		t.Fatalf("expected 1 generation after a restart, got %+v", gens)
+	}
+}
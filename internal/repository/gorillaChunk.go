@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// ErrOutOfOrder is returned when a sample is older than the last one of its chunk.
+var ErrOutOfOrder = errors.New("sample out of order")
+
+// GorillaChunk stores samples compressed as described in Facebook's Gorilla
+// paper: timestamps as delta-of-delta with variable-length prefixes and values
+// XORed with their predecessor, keeping only the meaningful bits.
+// A regular 2s series of slowly changing gauges takes a few bytes per sample
+// instead of 16.
+type GorillaChunk struct {
+	w bitWriter
+	n int
+
+	// Encoder state of the last sample
+	t        int64
+	delta    int64
+	v        float64
+	leading  uint8
+	trailing uint8
+}
+
+func NewGorillaChunk() *GorillaChunk {
+	return &GorillaChunk{}
+}
+
+// NumSamples returns the number of samples in the chunk.
+func (c *GorillaChunk) NumSamples() int {
+	return c.n
+}
+
+// Size returns the encoded size in bytes.
+func (c *GorillaChunk) Size() int {
+	return len(c.w.buf)
+}
+
+// MaxTime returns the timestamp of the last sample, Unix milliseconds.
+func (c *GorillaChunk) MaxTime() int64 {
+	return c.t
+}
+
+// Append adds a sample; t is in Unix milliseconds and must not decrease.
+func (c *GorillaChunk) Append(t int64, v float64) error {
+	if c.n > 0 && t < c.t {
+		return ErrOutOfOrder
+	}
+
+	switch c.n {
+	case 0:
+		c.w.writeBits(uint64(t), 64)
+		c.w.writeBits(math.Float64bits(v), 64)
+	default:
+		delta := t - c.t
+		c.writeDoD(delta - c.delta)
+		c.writeXOR(v)
+		c.delta = delta
+	}
+	c.t, c.v = t, v
+	c.n++
+
+	return nil
+}
+
+// Delta-of-delta buckets: '0' for zero, then prefixes with growing payloads.
+// The payload is the two's complement of the value in that many bits.
+var dodBuckets = []struct {
+	prefix, prefixLen uint64
+	bits              int
+}{
+	{0b10, 2, 14},
+	{0b110, 3, 17},
+	{0b1110, 4, 20},
+}
+
+func (c *GorillaChunk) writeDoD(dod int64) {
+	if dod == 0 {
+		c.w.writeBit(false)
+
+		return
+	}
+	for _, b := range dodBuckets {
+		if fitsBits(dod, b.bits) {
+			c.w.writeBits(b.prefix, int(b.prefixLen))
+			c.w.writeBits(uint64(dod), b.bits)
+
+			return
+		}
+	}
+	c.w.writeBits(0b1111, 4)
+	c.w.writeBits(uint64(dod), 64)
+}
+
+func (c *GorillaChunk) writeXOR(v float64) {
+	x := math.Float64bits(v) ^ math.Float64bits(c.v)
+	if x == 0 {
+		c.w.writeBit(false)
+
+		return
+	}
+	c.w.writeBit(true)
+
+	leading := uint8(bits.LeadingZeros64(x))
+	trailing := uint8(bits.TrailingZeros64(x))
+	// The leading count is stored in 5 bits
+	leading = min(leading, 31)
+
+	// Reuse the previous window when the meaningful bits fit into it;
+	// before the first window is written there is nothing worth reusing
+	if c.leading+c.trailing > 0 && leading >= c.leading && trailing >= c.trailing {
+		c.w.writeBit(false)
+		c.w.writeBits(x>>c.trailing, int(64-c.leading-c.trailing))
+
+		return
+	}
+	c.leading, c.trailing = leading, trailing
+	sigbits := 64 - leading - trailing
+	c.w.writeBit(true)
+	c.w.writeBits(uint64(leading), 5)
+	// 64 meaningful bits do not fit into 6 bits and are stored as 0
+	c.w.writeBits(uint64(sigbits)&0x3f, 6)
+	c.w.writeBits(x>>trailing, int(sigbits))
+}
+
+// Iterator returns an iterator over a snapshot of the chunk's samples.
+// Samples appended later are not visible to it.
+func (c *GorillaChunk) Iterator() *GorillaIterator {
+	// The last byte may still receive bits, so the reader gets its own copy of it
+	buf := append([]byte(nil), c.w.buf...)
+
+	return &GorillaIterator{r: bitReader{buf: buf}, total: c.n}
+}
+
+// GorillaIterator decodes the samples of a chunk in order.
+type GorillaIterator struct {
+	r     bitReader
+	total int
+	i     int
+	err   error
+
+	t        int64
+	delta    int64
+	v        float64
+	leading  uint8
+	trailing uint8
+}
+
+// Next advances to the next sample and reports whether there is one.
+func (it *GorillaIterator) Next() bool {
+	if it.err != nil || it.i >= it.total {
+		return false
+	}
+	if it.i == 0 {
+		t, err := it.r.readBits(64)
+		if err != nil {
+			return it.fail(err)
+		}
+		v, err := it.r.readBits(64)
+		if err != nil {
+			return it.fail(err)
+		}
+		it.t, it.v = int64(t), math.Float64frombits(v)
+		it.i++
+
+		return true
+	}
+
+	dod, err := it.readDoD()
+	if err != nil {
+		return it.fail(err)
+	}
+	it.delta += dod
+	it.t += it.delta
+	if err := it.readXOR(); err != nil {
+		return it.fail(err)
+	}
+	it.i++
+
+	return true
+}
+
+// At returns the current sample: Unix milliseconds and value.
+func (it *GorillaIterator) At() (int64, float64) {
+	return it.t, it.v
+}
+
+// Err returns the decoding error that stopped the iteration, if any.
+func (it *GorillaIterator) Err() error {
+	return it.err
+}
+
+func (it *GorillaIterator) fail(err error) bool {
+	it.err = err
+
+	return false
+}
+
+func (it *GorillaIterator) readDoD() (int64, error) {
+	// Count the leading ones of the prefix, at most four
+	ones := 0
+	for ones < 4 {
+		bit, err := it.r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		ones++
+	}
+	if ones == 0 {
+		return 0, nil
+	}
+	n := 64
+	if ones <= len(dodBuckets) {
+		n = dodBuckets[ones-1].bits
+	}
+	u, err := it.r.readBits(n)
+	if err != nil {
+		return 0, err
+	}
+	// Sign-extend the payload
+	return int64(u<<(64-n)) >> (64 - n), nil
+}
+
+func (it *GorillaIterator) readXOR() error {
+	changed, err := it.r.readBit()
+	if err != nil || !changed {
+		return err
+	}
+	newWindow, err := it.r.readBit()
+	if err != nil {
+		return err
+	}
+	if newWindow {
+		leading, err := it.r.readBits(5)
+		if err != nil {
+			return err
+		}
+		sigbits, err := it.r.readBits(6)
+		if err != nil {
+			return err
+		}
+		if sigbits == 0 {
+			sigbits = 64
+		}
+		it.leading = uint8(leading)
+		it.trailing = uint8(64 - leading - sigbits)
+	}
+	sigbits := int(64 - it.leading - it.trailing)
+	x, err := it.r.readBits(sigbits)
+	if err != nil {
+		return err
+	}
+	it.v = math.Float64frombits(math.Float64bits(it.v) ^ x<<it.trailing)
+
+	return nil
+}
+
+// fitsBits reports whether v fits into an n-bit two's complement integer.
+func fitsBits(v int64, n int) bool {
+	limit := int64(1) << (n - 1)
+
+	return v >= -limit && v < limit
+}
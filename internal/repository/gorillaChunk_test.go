@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type sample struct {
+	t int64
+	v float64
+}
+
+// gaugeSamples mimics an agent gauge: a 2s poll interval with jitter and
+// a slowly changing integer value, like HeapAlloc.
+func gaugeSamples(n int) []sample {
+	rng := rand.New(rand.NewSource(1))
+	out := make([]sample, n)
+	t := int64(1_700_000_000_000)
+	v := 4_000_000.0
+	for i := range out {
+		t += 2000 + rng.Int63n(20) - 10
+		if rng.Intn(4) == 0 {
+			v += float64(rng.Intn(65536) - 32768)
+		}
+		out[i] = sample{t, v}
+	}
+
+	return out
+}
+
+func TestGorillaChunk_RoundTrip(t *testing.T) {
+	samples := gaugeSamples(1000)
+	// Edge cases: repeated timestamps, huge gaps, special and random floats
+	samples = append(samples,
+		sample{samples[len(samples)-1].t, 0},
+		sample{samples[len(samples)-1].t + 1<<40, math.Inf(1)},
+		sample{samples[len(samples)-1].t + 1<<40 + 1, -0.0},
+		sample{samples[len(samples)-1].t + 1<<41, math.MaxFloat64},
+		sample{samples[len(samples)-1].t + 1<<41 + 3, math.SmallestNonzeroFloat64},
+		sample{samples[len(samples)-1].t + 1<<41 + 4, math.NaN()},
+		sample{samples[len(samples)-1].t + 1<<41 + 5, 1.0 / 3},
+	)
+
+	c := NewGorillaChunk()
+	for _, s := range samples {
+		if err := c.Append(s.t, s.v); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if c.NumSamples() != len(samples) {
+		t.Fatalf("NumSamples: got %d, want %d", c.NumSamples(), len(samples))
+	}
+
+	it := c.Iterator()
+	i := 0
+	for it.Next() {
+		ts, v := it.At()
+		want := samples[i]
+		if ts != want.t || math.Float64bits(v) != math.Float64bits(want.v) {
+			t.Fatalf("sample %d: got (%d, %v), want (%d, %v)", i, ts, v, want.t, want.v)
+		}
+		i++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterate: %v", err)
+	}
+	if i != len(samples) {
+		t.Fatalf("decoded %d samples, want %d", i, len(samples))
+	}
+
+	if err := c.Append(samples[0].t, 1); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("expected ErrOutOfOrder, got %v", err)
+	}
+}
+
+func TestGorillaChunk_IteratorSnapshot(t *testing.T) {
+	c := NewGorillaChunk()
+	_ = c.Append(1000, 1)
+	_ = c.Append(2000, 2)
+	it := c.Iterator()
+	_ = c.Append(3000, 3)
+
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 2 || it.Err() != nil {
+		t.Fatalf("iterator must see the samples at its creation: %d, %v", n, it.Err())
+	}
+}
+
+func BenchmarkGorillaChunk(b *testing.B) {
+	samples := gaugeSamples(120)
+	b.ReportAllocs()
+	var size int
+	for i := 0; i < b.N; i++ {
+		c := NewGorillaChunk()
+		for _, s := range samples {
+			_ = c.Append(s.t, s.v)
+		}
+		size = c.Size()
+	}
+	b.ReportMetric(float64(size)/float64(len(samples)), "bytes/sample")
+}
+
+func BenchmarkGorillaIterator(b *testing.B) {
+	samples := gaugeSamples(120)
+	c := NewGorillaChunk()
+	for _, s := range samples {
+		_ = c.Append(s.t, s.v)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := c.Iterator()
+		for it.Next() {
+			_, _ = it.At()
+		}
+	}
+}
+
+// BenchmarkRawSamples is the uncompressed baseline: a slice of timestamp and value pairs.
+func BenchmarkRawSamples(b *testing.B) {
+	samples := gaugeSamples(120)
+	b.ReportAllocs()
+	var size int
+	for i := 0; i < b.N; i++ {
+		var raw []sample
+		for _, s := range samples {
+			raw = append(raw, s)
+		}
+		size = cap(raw) * 16
+	}
+	b.ReportMetric(float64(size)/float64(len(samples)), "bytes/sample")
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// gorillaChunkSamples is the number of samples per chunk; 120 samples of
+// a 2s series cover four minutes, about where compression levels off.
+const gorillaChunkSamples = 120
+
+// GorillaHistory keeps samples of every series in Gorilla-compressed chunks.
+// Retention and the per-series sample limit are applied by dropping
+// whole chunks, so a series may briefly hold one chunk more than the limits.
+type GorillaHistory struct {
+	mu        sync.RWMutex
+	retention time.Duration
+	capacity  int
+	series    map[string][]*GorillaChunk
+}
+
+func NewGorillaHistory(retention time.Duration, capacity int) *GorillaHistory {
+	return &GorillaHistory{
+		retention: retention,
+		capacity:  capacity,
+		series:    make(map[string][]*GorillaChunk),
+	}
+}
+
+// Append records value v of series at t. A sample older than the last one
+// of the series, as from writers racing to append or a clock stepping back,
+// is recorded at the last timestamp instead, so chunks stay ordered.
+func (h *GorillaHistory) Append(series string, t time.Time, v float64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	chunks := h.series[series]
+	ts := t.UnixMilli()
+	if n := len(chunks); n > 0 {
+		ts = max(ts, chunks[n-1].MaxTime())
+	}
+	if n := len(chunks); n == 0 || chunks[n-1].NumSamples() >= gorillaChunkSamples {
+		chunks = append(chunks, NewGorillaChunk())
+	}
+	if err := chunks[len(chunks)-1].Append(ts, v); err != nil {
+		return err
+	}
+
+	// Drop the oldest chunk once it is past the retention or
+	// the rest of the series alone reaches the sample limit
+	cutoff := t.Add(-h.retention).UnixMilli()
+	total := 0
+	for _, c := range chunks {
+		total += c.NumSamples()
+	}
+	for len(chunks) > 1 && (chunks[0].MaxTime() < cutoff || total-chunks[0].NumSamples() >= h.capacity) {
+		total -= chunks[0].NumSamples()
+		chunks[0] = nil
+		chunks = chunks[1:]
+	}
+	h.series[series] = chunks
+
+	return nil
+}
+
+// Query returns the samples of series within [from, to], oldest first.
+func (h *GorillaHistory) Query(series string, from, to time.Time) ([]models.Point, error) {
+	h.mu.RLock()
+	chunks := h.series[series]
+	// Iterators copy the chunk data, so decoding can run without the lock
+	iters := make([]*GorillaIterator, 0, len(chunks))
+	lo := max(from.UnixMilli(), time.Now().Add(-h.retention).UnixMilli())
+	hi := to.UnixMilli()
+	for _, c := range chunks {
+		if c.MaxTime() >= lo {
+			iters = append(iters, c.Iterator())
+		}
+	}
+	h.mu.RUnlock()
+
+	var out []models.Point
+	for _, it := range iters {
+		for it.Next() {
+			t, v := it.At()
+			if t > hi {
+				break
+			}
+			if t >= lo {
+				out = append(out, models.Point{Timestamp: t, Value: v})
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
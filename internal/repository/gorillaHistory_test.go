@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGorillaHistory(t *testing.T) {
+	h := NewGorillaHistory(time.Hour, 200)
+	start := time.Now().Add(-30 * time.Minute)
+
+	for i := 0; i < 500; i++ {
+		if err := h.Append("gauge/a", start.Add(time.Duration(i)*time.Second), float64(i)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// Whole chunks are dropped, the newest samples are always kept
+	points, err := h.Query("gauge/a", start, time.Now())
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(points) < 200 || len(points) > 200+gorillaChunkSamples {
+		t.Fatalf("unexpected number of samples: %d", len(points))
+	}
+	last := points[len(points)-1]
+	if last.Value != 499 || last.Timestamp != start.Add(499*time.Second).UnixMilli() {
+		t.Fatalf("unexpected last sample: %+v", last)
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].Value != points[i-1].Value+1 {
+			t.Fatalf("samples not contiguous at %d: %+v", i, points[i-1:i+1])
+		}
+	}
+
+	points, _ = h.Query("gauge/a", start.Add(450*time.Second), start.Add(452*time.Second))
+	if len(points) != 3 || points[0].Value != 450 {
+		t.Fatalf("unexpected range: %+v", points)
+	}
+
+	// Chunks entirely past the retention are dropped on append
+	if err := h.Append("gauge/a", start.Add(3*time.Hour), 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if n := len(h.series["gauge/a"]); n != 1 {
+		t.Fatalf("expected only the head chunk to remain, got %d chunks", n)
+	}
+}
+
+func TestGorillaHistory_LateSample(t *testing.T) {
+	h := NewGorillaHistory(time.Hour, 1000)
+	start := time.Now().Add(-time.Minute)
+
+	// The late sample lands in a fresh chunk, which alone would accept it
+	for i := 0; i < gorillaChunkSamples; i++ {
+		if err := h.Append("gauge/a", start.Add(time.Duration(i)*time.Millisecond), float64(i)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := h.Append("gauge/a", start, -1); err != nil {
+		t.Fatalf("late sample: %v", err)
+	}
+
+	points, err := h.Query("gauge/a", start, time.Now())
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(points) != gorillaChunkSamples+1 {
+		t.Fatalf("expected %d samples, got %d", gorillaChunkSamples+1, len(points))
+	}
+	last := points[len(points)-1]
+	if last.Value != -1 || last.Timestamp != points[len(points)-2].Timestamp {
+		t.Fatalf("late sample not recorded at the last timestamp: %+v", last)
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// MemHistory keeps the latest samples of every series in a fixed-size ring
+// buffer. Samples older than the retention are dropped on append and hidden
+// from queries.
+type MemHistory struct {
+	mu        sync.RWMutex
+	retention time.Duration
+	capacity  int
+	series    map[string]*pointRing
+}
+
+func NewMemHistory(retention time.Duration, capacity int) *MemHistory {
+	return &MemHistory{
+		retention: retention,
+		capacity:  capacity,
+		series:    make(map[string]*pointRing),
+	}
+}
+
+// Append records value v of series at t. Samples are expected in time order.
+func (h *MemHistory) Append(series string, t time.Time, v float64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.series[series]
+	if !ok {
+		r = &pointRing{buf: make([]models.Point, h.capacity)}
+		h.series[series] = r
+	}
+	r.push(models.Point{Timestamp: t.UnixMilli(), Value: v})
+	r.dropBefore(t.Add(-h.retention).UnixMilli())
+
+	return nil
+}
+
+// Query returns the samples of series within [from, to], oldest first.
+func (h *MemHistory) Query(series string, from, to time.Time) ([]models.Point, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.series[series]
+	if !ok {
+		return nil, nil
+	}
+	lo := max(from.UnixMilli(), time.Now().Add(-h.retention).UnixMilli())
+	hi := to.UnixMilli()
+
+	var out []models.Point
+	for i := 0; i < r.n; i++ {
+		p := r.at(i)
+		if p.Timestamp >= lo && p.Timestamp <= hi {
+			out = append(out, p)
+		}
+	}
+
+	return out, nil
+}
+
+// pointRing overwrites the oldest point once full.
+type pointRing struct {
+	buf   []models.Point
+	start int
+	n     int
+}
+
+func (r *pointRing) push(p models.Point) {
+	if len(r.buf) == 0 {
+		return
+	}
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = p
+		r.n++
+
+		return
+	}
+	r.buf[r.start] = p
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+func (r *pointRing) at(i int) models.Point {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+func (r *pointRing) dropBefore(ts int64) {
+	for r.n > 0 && r.at(0).Timestamp < ts {
+		r.start = (r.start + 1) % len(r.buf)
+		r.n--
+	}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemHistory_RingAndRetention(t *testing.T) {
+	h := NewMemHistory(time.Hour, 3)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if err := h.Append("gauge/a", now.Add(time.Duration(i-5)*time.Second), float64(i)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// Only the last 3 samples fit into the ring
+	points, err := h.Query("gauge/a", now.Add(-time.Minute), now)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(points) != 3 || points[0].Value != 2 || points[2].Value != 4 {
+		t.Fatalf("unexpected points: %+v", points)
+	}
+
+	points, _ = h.Query("gauge/a", now.Add(-3*time.Second), now.Add(-2*time.Second))
+	if len(points) != 2 || points[0].Value != 2 || points[1].Value != 3 {
+		t.Fatalf("unexpected range: %+v", points)
+	}
+
+	if points, _ := h.Query("gauge/missing", now.Add(-time.Hour), now); len(points) != 0 {
+		t.Fatalf("missing series must be empty: %+v", points)
+	}
+
+	// An append far in the future pushes everything out of the retention
+	if err := h.Append("gauge/a", now.Add(2*time.Hour), 9); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	points, _ = h.Query("gauge/a", now.Add(-time.Hour), now.Add(3*time.Hour))
+	if len(points) != 1 || points[0].Value != 9 {
+		t.Fatalf("old samples not dropped: %+v", points)
+	}
+}
@@ -1,49 +1,73 @@
 package repository
 
-import "sync"
+import (
+	"errors"
+	"sync"
+	"time"
 
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+)
+
+var (
+	// ErrHistogramNotFound is returned when observing a missing histogram without buckets.
+	ErrHistogramNotFound = errors.New("histogram not found")
+	// ErrBucketsMismatch is returned when the declared buckets differ from the stored ones.
+	ErrBucketsMismatch = errors.New("histogram buckets mismatch")
+)
+
+// MemStorage keeps metrics in memory, keyed by series key (see models.SeriesKey).
 type MemStorage struct {
-	mu       sync.RWMutex
-	counters map[string]int64
-	gauges   map[string]float64
+	mu         sync.RWMutex
+	counters   map[string]int64
+	gauges     map[string]float64
+	histograms map[string]*sketch.Histogram
+	summaries  map[string]*sketch.Summary
+	sets       map[string]*sketch.HyperLogLog
 }
 
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		gauges:   make(map[string]float64),
-		counters: make(map[string]int64),
+		gauges:     make(map[string]float64),
+		counters:   make(map[string]int64),
+		histograms: make(map[string]*sketch.Histogram),
+		summaries:  make(map[string]*sketch.Summary),
+		sets:       make(map[string]*sketch.HyperLogLog),
 	}
 }
 
-func (m *MemStorage) UpdateGauge(name string, value float64) {
+func (m *MemStorage) UpdateGauge(name string, value float64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.gauges[name] = value
+
+	return nil
 }
 
-func (m *MemStorage) UpdateCounter(name string, delta int64) {
+func (m *MemStorage) UpdateCounter(name string, delta int64) (int64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.counters[name] += delta
+
+	return m.counters[name], nil
 }
 
-func (m *MemStorage) GetGauge(name string) (float64, bool) {
+func (m *MemStorage) GetGauge(name string) (float64, bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	v, ok := m.gauges[name]
 
-	return v, ok
+	return v, ok, nil
 }
 
-func (m *MemStorage) GetCounter(name string) (int64, bool) {
+func (m *MemStorage) GetCounter(name string) (int64, bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	v, ok := m.counters[name]
 
-	return v, ok
+	return v, ok, nil
 }
 
-func (m *MemStorage) AllGauges() map[string]float64 {
+func (m *MemStorage) AllGauges() (map[string]float64, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	out := make(map[string]float64, len(m.gauges))
@@ -51,10 +75,10 @@ func (m *MemStorage) AllGauges() map[string]float64 {
 		out[k] = v
 	}
 
-	return out
+	return out, nil
 }
 
-func (m *MemStorage) AllCounters() map[string]int64 {
+func (m *MemStorage) AllCounters() (map[string]int64, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	out := make(map[string]int64, len(m.counters))
@@ -62,5 +86,214 @@ func (m *MemStorage) AllCounters() map[string]int64 {
 		out[k] = v
 	}
 
-	return out
+	return out, nil
+}
+
+// UpdateBatch applies gauges and counter deltas under a single lock,
+// so readers never observe a partially applied batch.
+// It returns the resulting totals of the given counters.
+func (m *MemStorage) UpdateBatch(gauges map[string]float64, counters map[string]int64) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range gauges {
+		m.gauges[k] = v
+	}
+	totals := make(map[string]int64, len(counters))
+	for k, d := range counters {
+		m.counters[k] += d
+		totals[k] = m.counters[k]
+	}
+
+	return totals, nil
+}
+
+// ObserveHistogram adds value to the named histogram.
+// A missing histogram is created with bounds; nil bounds only observe into
+// an existing one. Non-nil bounds must match those of an existing histogram.
+func (m *MemStorage) ObserveHistogram(name string, bounds []float64, value float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.histograms[name]
+	switch {
+	case ok && bounds != nil && !h.SameBounds(bounds):
+		return ErrBucketsMismatch
+	case !ok && bounds == nil:
+		return ErrHistogramNotFound
+	case !ok:
+		var err error
+		if h, err = sketch.NewHistogram(bounds); err != nil {
+			return err
+		}
+		m.histograms[name] = h
+	}
+	h.Observe(value)
+
+	return nil
+}
+
+func (m *MemStorage) GetHistogram(name string) (*sketch.Histogram, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.histograms[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return h.Clone(), true, nil
+}
+
+func (m *MemStorage) AllHistograms() (map[string]*sketch.Histogram, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]*sketch.Histogram, len(m.histograms))
+	for k, h := range m.histograms {
+		out[k] = h.Clone()
+	}
+
+	return out, nil
+}
+
+// RestoreHistograms replaces the given histograms with copies of the provided ones.
+func (m *MemStorage) RestoreHistograms(histograms map[string]*sketch.Histogram) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, h := range histograms {
+		m.histograms[k] = h.Clone()
+	}
+
+	return nil
+}
+
+// ObserveSummary adds value observed at now to the named summary,
+// creating it with the given window when missing.
+func (m *MemStorage) ObserveSummary(name string, maxAge time.Duration, value float64, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sm, ok := m.summaries[name]
+	if !ok {
+		var err error
+		if sm, err = sketch.NewSummary(maxAge, 0); err != nil {
+			return err
+		}
+		m.summaries[name] = sm
+	}
+	sm.Observe(value, now)
+
+	return nil
+}
+
+func (m *MemStorage) GetSummary(name string) (*sketch.Summary, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sm, ok := m.summaries[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return sm.Clone(), true, nil
+}
+
+func (m *MemStorage) AllSummaries() (map[string]*sketch.Summary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]*sketch.Summary, len(m.summaries))
+	for k, sm := range m.summaries {
+		out[k] = sm.Clone()
+	}
+
+	return out, nil
+}
+
+// RestoreSummaries replaces the given summaries with copies of the provided ones.
+func (m *MemStorage) RestoreSummaries(summaries map[string]*sketch.Summary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, sm := range summaries {
+		m.summaries[k] = sm.Clone()
+	}
+
+	return nil
+}
+
+// AddSetMember adds member to the named set, creating it with the given
+// precision when missing.
+func (m *MemStorage) AddSetMember(name string, precision uint8, member string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hll, ok := m.sets[name]
+	if !ok {
+		var err error
+		if hll, err = sketch.NewHyperLogLog(precision); err != nil {
+			return err
+		}
+		m.sets[name] = hll
+	}
+	hll.Add(member)
+
+	return nil
+}
+
+func (m *MemStorage) GetSet(name string) (*sketch.HyperLogLog, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hll, ok := m.sets[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return hll.Clone(), true, nil
+}
+
+func (m *MemStorage) AllSets() (map[string]*sketch.HyperLogLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]*sketch.HyperLogLog, len(m.sets))
+	for k, hll := range m.sets {
+		out[k] = hll.Clone()
+	}
+
+	return out, nil
+}
+
+// RestoreSets replaces the given sets with copies of the provided ones.
+func (m *MemStorage) RestoreSets(sets map[string]*sketch.HyperLogLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, hll := range sets {
+		m.sets[k] = hll.Clone()
+	}
+
+	return nil
+}
+
+// ReplaceState swaps the whole content for copies of state in one step,
+// so readers never see a mix of the old and the new metrics.
+func (m *MemStorage) ReplaceState(state State) error {
+	gauges := make(map[string]float64, len(state.Gauges))
+	for k, v := range state.Gauges {
+		gauges[k] = v
+	}
+	counters := make(map[string]int64, len(state.Counters))
+	for k, v := range state.Counters {
+		counters[k] = v
+	}
+	histograms := make(map[string]*sketch.Histogram, len(state.Histograms))
+	for k, h := range state.Histograms {
+		histograms[k] = h.Clone()
+	}
+	summaries := make(map[string]*sketch.Summary, len(state.Summaries))
+	for k, sm := range state.Summaries {
+		summaries[k] = sm.Clone()
+	}
+	sets := make(map[string]*sketch.HyperLogLog, len(state.Sets))
+	for k, hll := range state.Sets {
+		sets[k] = hll.Clone()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges, m.counters = gauges, counters
+	m.histograms, m.summaries, m.sets = histograms, summaries, sets
+
+	return nil
 }
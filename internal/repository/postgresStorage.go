@@ -0,0 +1,539 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+
+	// Registers the "pgx" database/sql driver.
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const pgQueryTimeout = 5 * time.Second
+
+// PostgresStorage keeps metrics in PostgreSQL.
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+// NewPostgresStorage connects to the database and applies pending migrations.
+func NewPostgresStorage(ctx context.Context, dsn string, migrations fs.FS) (*PostgresStorage, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+	if err := migrate(ctx, db, migrations); err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("migrate database: %w", err)
+	}
+
+	return &PostgresStorage{db: db}, nil
+}
+
+func (p *PostgresStorage) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+func (p *PostgresStorage) Close() error {
+	return p.db.Close()
+}
+
+func (p *PostgresStorage) UpdateGauge(name string, value float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	_, err := p.db.ExecContext(ctx, upsertGaugeSQL, name, value)
+
+	return err
+}
+
+func (p *PostgresStorage) UpdateCounter(name string, delta int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	var total int64
+	err := p.db.QueryRowContext(ctx, upsertCounterSQL, name, delta).Scan(&total)
+
+	return total, err
+}
+
+// UpdateBatch applies all gauges and counter deltas in one transaction
+// and returns the resulting totals of the given counters.
+func (p *PostgresStorage) UpdateBatch(gauges map[string]float64, counters map[string]int64) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Stable order keeps row locks acquired in the same order across batches
+	for _, name := range sortedNames(gauges) {
+		if _, err := tx.ExecContext(ctx, upsertGaugeSQL, name, gauges[name]); err != nil {
+			return nil, err
+		}
+	}
+	totals := make(map[string]int64, len(counters))
+	for _, name := range sortedNames(counters) {
+		var total int64
+		if err := tx.QueryRowContext(ctx, upsertCounterSQL, name, counters[name]).Scan(&total); err != nil {
+			return nil, err
+		}
+		totals[name] = total
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (p *PostgresStorage) GetGauge(name string) (float64, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	var v float64
+	err := p.db.QueryRowContext(ctx, `SELECT value FROM gauges WHERE name = $1`, name).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get gauge %q: %w", name, err)
+	}
+
+	return v, true, nil
+}
+
+func (p *PostgresStorage) GetCounter(name string) (int64, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	var v int64
+	err := p.db.QueryRowContext(ctx, `SELECT value FROM counters WHERE name = $1`, name).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get counter %q: %w", name, err)
+	}
+
+	return v, true, nil
+}
+
+func (p *PostgresStorage) AllGauges() (map[string]float64, error) {
+	out := make(map[string]float64)
+	err := p.queryAll(`SELECT name, value FROM gauges`, func(rows *sql.Rows) error {
+		var name string
+		var v float64
+		if err := rows.Scan(&name, &v); err != nil {
+			return err
+		}
+		out[name] = v
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list gauges: %w", err)
+	}
+
+	return out, nil
+}
+
+func (p *PostgresStorage) AllCounters() (map[string]int64, error) {
+	out := make(map[string]int64)
+	err := p.queryAll(`SELECT name, value FROM counters`, func(rows *sql.Rows) error {
+		var name string
+		var v int64
+		if err := rows.Scan(&name, &v); err != nil {
+			return err
+		}
+		out[name] = v
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list counters: %w", err)
+	}
+
+	return out, nil
+}
+
+// ObserveHistogram adds value to the named histogram, with the same rules
+// for bounds as MemStorage.ObserveHistogram.
+func (p *PostgresStorage) ObserveHistogram(name string, bounds []float64, value float64) error {
+	create := func() (*sketch.Histogram, error) {
+		if bounds == nil {
+			return nil, ErrHistogramNotFound
+		}
+
+		return sketch.NewHistogram(bounds)
+	}
+
+	return updateSketch(p, "histograms", name, create, func(h *sketch.Histogram) error {
+		if bounds != nil && !h.SameBounds(bounds) {
+			return ErrBucketsMismatch
+		}
+		h.Observe(value)
+
+		return nil
+	})
+}
+
+func (p *PostgresStorage) GetHistogram(name string) (*sketch.Histogram, bool, error) {
+	return getSketch[sketch.Histogram](p, "histograms", name)
+}
+
+func (p *PostgresStorage) AllHistograms() (map[string]*sketch.Histogram, error) {
+	return allSketches[sketch.Histogram](p, "histograms")
+}
+
+// RestoreHistograms replaces the given histograms in one transaction.
+func (p *PostgresStorage) RestoreHistograms(histograms map[string]*sketch.Histogram) error {
+	return restoreSketches(p, "histograms", histograms)
+}
+
+// ObserveSummary adds value observed at now to the named summary,
+// creating it with the given window when missing.
+func (p *PostgresStorage) ObserveSummary(name string, maxAge time.Duration, value float64, now time.Time) error {
+	create := func() (*sketch.Summary, error) {
+		return sketch.NewSummary(maxAge, 0)
+	}
+
+	return updateSketch(p, "summaries", name, create, func(sm *sketch.Summary) error {
+		sm.Observe(value, now)
+
+		return nil
+	})
+}
+
+func (p *PostgresStorage) GetSummary(name string) (*sketch.Summary, bool, error) {
+	return getSketch[sketch.Summary](p, "summaries", name)
+}
+
+func (p *PostgresStorage) AllSummaries() (map[string]*sketch.Summary, error) {
+	return allSketches[sketch.Summary](p, "summaries")
+}
+
+// RestoreSummaries replaces the given summaries in one transaction.
+func (p *PostgresStorage) RestoreSummaries(summaries map[string]*sketch.Summary) error {
+	return restoreSketches(p, "summaries", summaries)
+}
+
+// AddSetMember adds member to the named set, creating it with the given
+// precision when missing.
+func (p *PostgresStorage) AddSetMember(name string, precision uint8, member string) error {
+	create := func() (*sketch.HyperLogLog, error) {
+		return sketch.NewHyperLogLog(precision)
+	}
+
+	return updateSketch(p, "sets", name, create, func(hll *sketch.HyperLogLog) error {
+		hll.Add(member)
+
+		return nil
+	})
+}
+
+func (p *PostgresStorage) GetSet(name string) (*sketch.HyperLogLog, bool, error) {
+	return getSketch[sketch.HyperLogLog](p, "sets", name)
+}
+
+func (p *PostgresStorage) AllSets() (map[string]*sketch.HyperLogLog, error) {
+	return allSketches[sketch.HyperLogLog](p, "sets")
+}
+
+// RestoreSets replaces the given sets in one transaction.
+func (p *PostgresStorage) RestoreSets(sets map[string]*sketch.HyperLogLog) error {
+	return restoreSketches(p, "sets", sets)
+}
+
+// sketchPtr is a pointer to a sketch kept as JSON in a table of
+// 0002_create_sketches.sql.
+type sketchPtr[S any] interface {
+	*S
+	Validate() error
+}
+
+// updateSketch applies change to the sketch stored under name in table,
+// holding its row lock. A missing sketch is made by create first.
+func updateSketch[S any, T sketchPtr[S]](p *PostgresStorage, table, name string, create func() (T, error), change func(T) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	for {
+		created, err := updateSketchTx(ctx, p.db, table, name, create, change)
+		if err != nil {
+			return fmt.Errorf("update %s %q: %w", table, name, err)
+		}
+		// Otherwise a concurrent writer inserted the row first, retry on it
+		if created {
+			return nil
+		}
+	}
+}
+
+// updateSketchTx makes one attempt of updateSketch. It reports false when
+// the row was missing on select but present on insert.
+func updateSketchTx[S any, T sketchPtr[S]](ctx context.Context, db *sql.DB, table, name string,
+	create func() (T, error), change func(T) error,
+) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var raw []byte
+	err = tx.QueryRowContext(ctx, `SELECT state FROM `+table+` WHERE name = $1 FOR UPDATE`, name).Scan(&raw)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		s, err := create()
+		if err != nil {
+			return false, err
+		}
+		if err := change(s); err != nil {
+			return false, err
+		}
+		state, err := json.Marshal(s)
+		if err != nil {
+			return false, err
+		}
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO `+table+` (name, state) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`, name, state)
+		if err != nil {
+			return false, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	default:
+		s, err := decodeSketch[S, T](raw)
+		if err != nil {
+			return false, err
+		}
+		if err := change(s); err != nil {
+			return false, err
+		}
+		state, err := json.Marshal(s)
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET state = $2 WHERE name = $1`, name, state); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func getSketch[S any, T sketchPtr[S]](p *PostgresStorage, table, name string) (T, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	var raw []byte
+	err := p.db.QueryRowContext(ctx, `SELECT state FROM `+table+` WHERE name = $1`, name).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get %s %q: %w", table, name, err)
+	}
+	s, err := decodeSketch[S, T](raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("get %s %q: %w", table, name, err)
+	}
+
+	return s, true, nil
+}
+
+func allSketches[S any, T sketchPtr[S]](p *PostgresStorage, table string) (map[string]T, error) {
+	out := make(map[string]T)
+	err := p.queryAll(`SELECT name, state FROM `+table, func(rows *sql.Rows) error {
+		var name string
+		var raw []byte
+		if err := rows.Scan(&name, &raw); err != nil {
+			return err
+		}
+		s, err := decodeSketch[S, T](raw)
+		if err != nil {
+			return fmt.Errorf("%q: %w", name, err)
+		}
+		out[name] = s
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", table, err)
+	}
+
+	return out, nil
+}
+
+func restoreSketches[T any](p *PostgresStorage, table string, sketches map[string]T) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, name := range sortedNames(sketches) {
+		state, err := json.Marshal(sketches[name])
+		if err != nil {
+			return fmt.Errorf("restore %s %q: %w", table, name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+table+` (name, state) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET state = EXCLUDED.state`, name, state); err != nil {
+			return fmt.Errorf("restore %s %q: %w", table, name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// decodeSketch parses a stored sketch, rejecting states that would make
+// its methods panic.
+func decodeSketch[S any, T sketchPtr[S]](raw []byte) (T, error) {
+	s := T(new(S))
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (p *PostgresStorage) queryAll(query string, scan func(*sql.Rows) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+const (
+	upsertGaugeSQL = `INSERT INTO gauges (name, value) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value`
+	upsertCounterSQL = `INSERT INTO counters (name, value) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET value = counters.value + EXCLUDED.value
+RETURNING value`
+)
+
+// migrateLockID is the advisory lock key serializing migrations of
+// replicas sharing the database.
+const migrateLockID = 7265746963
+
+// migrate applies every *.sql file from migrations that is not yet recorded
+// in schema_migrations. Each file runs in its own transaction. The whole run
+// holds an advisory lock, so replicas starting together apply each file once.
+func migrate(ctx context.Context, db *sql.DB, migrations fs.FS) (err error) {
+	// Session-level advisory locks belong to a connection, not to the pool
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrateLockID); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+	defer func() {
+		// Unlock even when ctx is done, the connection goes back to the pool
+		unlockCtx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+		defer cancel()
+		if _, unlockErr := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock($1)`, migrateLockID); unlockErr != nil && err == nil {
+			err = fmt.Errorf("unlock migrations: %w", unlockErr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		// Checked under the lock: another replica may have applied it meanwhile
+		var applied bool
+		err := conn.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, file).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		script, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, conn, file, string(script)); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, version, script string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+	"github.com/xGuthub/metrics-collection-service/migrations"
+)
+
+// newTestPostgres connects to the database from TEST_DATABASE_DSN,
+// e.g. a local container started with
+// docker run --rm -e POSTGRES_PASSWORD=postgres -p 5432:5432 postgres.
+func newTestPostgres(t *testing.T) *PostgresStorage {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p, err := NewPostgresStorage(ctx, dsn, migrations.FS)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := p.db.ExecContext(ctx, `TRUNCATE gauges, counters, histograms, summaries, sets`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	return p
+}
+
+func TestPostgresStorage_GaugesAndCounters(t *testing.T) {
+	p := newTestPostgres(t)
+
+	if err := p.UpdateGauge("temp", 1.5); err != nil {
+		t.Fatalf("update gauge: %v", err)
+	}
+	if err := p.UpdateGauge("temp", 2.5); err != nil {
+		t.Fatalf("update gauge: %v", err)
+	}
+	if _, err := p.UpdateCounter("hits", 3); err != nil {
+		t.Fatalf("update counter: %v", err)
+	}
+	if total, err := p.UpdateCounter("hits", 4); err != nil || total != 7 {
+		t.Fatalf("update counter: got (%v, %v)", total, err)
+	}
+
+	if v, ok, err := p.GetGauge("temp"); err != nil || !ok || v != 2.5 {
+		t.Fatalf("gauge: got (%v, %v, %v)", v, ok, err)
+	}
+	if v, ok, err := p.GetCounter("hits"); err != nil || !ok || v != 7 {
+		t.Fatalf("counter: got (%v, %v, %v)", v, ok, err)
+	}
+	if _, ok, err := p.GetGauge("missing"); err != nil || ok {
+		t.Fatalf("missing gauge: got (%v, %v)", ok, err)
+	}
+}
+
+func TestPostgresStorage_UpdateBatch(t *testing.T) {
+	p := newTestPostgres(t)
+
+	_, err := p.UpdateBatch(map[string]float64{"a": 1, "b": 2}, map[string]int64{"c": 5})
+	if err != nil {
+		t.Fatalf("update batch: %v", err)
+	}
+	if totals, err := p.UpdateBatch(nil, map[string]int64{"c": 5}); err != nil || totals["c"] != 10 {
+		t.Fatalf("update batch: got (%v, %v)", totals, err)
+	}
+
+	if g, err := p.AllGauges(); err != nil || len(g) != 2 || g["a"] != 1 || g["b"] != 2 {
+		t.Fatalf("unexpected gauges: %v, %v", g, err)
+	}
+	if c, err := p.AllCounters(); err != nil || len(c) != 1 || c["c"] != 10 {
+		t.Fatalf("unexpected counters: %v, %v", c, err)
+	}
+}
+
+func TestPostgresStorage_MigrateIsIdempotent(t *testing.T) {
+	p := newTestPostgres(t)
+
+	if err := migrate(context.Background(), p.db, migrations.FS); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+}
+
+func TestPostgresStorage_ConcurrentMigrate(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+	// Start over, as a fresh database shared by replicas starting together
+	if _, err := p.db.ExecContext(ctx, `DROP TABLE IF EXISTS schema_migrations, gauges, counters`); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- migrate(ctx, p.db, migrations.FS) }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("concurrent migrate: %v", err)
+		}
+	}
+}
+
+func TestPostgresStorage_Sketches(t *testing.T) {
+	p := newTestPostgres(t)
+
+	if err := p.ObserveHistogram("lat", nil, 1); !errors.Is(err, ErrHistogramNotFound) {
+		t.Fatalf("observe without bounds: expected ErrHistogramNotFound, got %v", err)
+	}
+	bounds := []float64{0.5, 1}
+	for _, v := range []float64{0.2, 0.7, 3} {
+		if err := p.ObserveHistogram("lat", bounds, v); err != nil {
+			t.Fatalf("observe histogram: %v", err)
+		}
+	}
+	if err := p.ObserveHistogram("lat", []float64{2}, 1); !errors.Is(err, ErrBucketsMismatch) {
+		t.Fatalf("other bounds: expected ErrBucketsMismatch, got %v", err)
+	}
+	h, ok, err := p.GetHistogram("lat")
+	if err != nil || !ok || h.Count != 3 || h.Counts[0] != 1 || h.Counts[1] != 1 || h.Counts[2] != 1 {
+		t.Fatalf("histogram: got (%+v, %v, %v)", h, ok, err)
+	}
+
+	now := time.Now()
+	for _, v := range []float64{1, 2, 3} {
+		if err := p.ObserveSummary("rt", time.Minute, v, now); err != nil {
+			t.Fatalf("observe summary: %v", err)
+		}
+	}
+	if sm, ok, err := p.GetSummary("rt"); err != nil || !ok || sm.Snapshot(now).Quantile(0.5) != 2 {
+		t.Fatalf("summary: got (%v, %v)", ok, err)
+	}
+
+	for _, m := range []string{"a", "b", "a"} {
+		if err := p.AddSetMember("users", 14, m); err != nil {
+			t.Fatalf("add set member: %v", err)
+		}
+	}
+	if sets, err := p.AllSets(); err != nil || len(sets) != 1 || sets["users"].Estimate() != 2 {
+		t.Fatalf("sets: got (%v, %v)", sets, err)
+	}
+
+	// Restoring replaces stored sketches
+	fresh, _ := sketch.NewHistogram(bounds)
+	if err := p.RestoreHistograms(map[string]*sketch.Histogram{"lat": fresh}); err != nil {
+		t.Fatalf("restore histograms: %v", err)
+	}
+	if all, err := p.AllHistograms(); err != nil || all["lat"].Count != 0 {
+		t.Fatalf("restored histogram: got (%v, %v)", all, err)
+	}
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the encoding of a snapshot body.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// snapshotFormat and snapshotVersion identify the header line of a snapshot.
+// Files without it are dumps written before headers existed.
+const (
+	snapshotFormat  = "metrics-snapshot"
+	snapshotVersion = 1
+)
+
+// ErrCorruptSnapshot is returned for snapshots that fail the integrity checks.
+var ErrCorruptSnapshot = errors.New("corrupt snapshot")
+
+// snapshotHeader is the first line of a snapshot file; the body follows it.
+// The checksum covers the body as stored, so corruption is detected before
+// the body is decompressed.
+type snapshotHeader struct {
+	Format      string      `json:"format"`
+	Version     int         `json:"version"`
+	Created     time.Time   `json:"created"`
+	Compression Compression `json:"compression"`
+	Size        int         `json:"size"`
+	Checksum    string      `json:"checksum"`
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// encodeSnapshot prepends the header to the compressed body.
+func encodeSnapshot(body []byte, c Compression, created time.Time) ([]byte, error) {
+	var err error
+	switch c {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	case CompressionZstd:
+		var enc *zstd.Encoder
+		enc, err = zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		body = enc.EncodeAll(body, nil)
+		_ = enc.Close()
+	case CompressionNone, "":
+		c = CompressionNone
+	default:
+		return nil, fmt.Errorf("unsupported snapshot compression %q", c)
+	}
+
+	sum := sha256.Sum256(body)
+	header, err := json.Marshal(snapshotHeader{
+		Format:      snapshotFormat,
+		Version:     snapshotVersion,
+		Created:     created.UTC(),
+		Compression: c,
+		Size:        len(body),
+		Checksum:    "sha256:" + hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(header)+1+len(body))
+	out = append(out, header...)
+	out = append(out, '\n')
+
+	return append(out, body...), nil
+}
+
+// decodeSnapshot verifies a snapshot and returns its uncompressed body.
+// Data without a header is returned as is. The compression is detected
+// from the body itself.
+func decodeSnapshot(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(`{"format":"`+snapshotFormat+`"`)) {
+		return data, nil
+	}
+	line, body, ok := bytes.Cut(data, []byte{'\n'})
+	if !ok {
+		return nil, fmt.Errorf("%w: truncated header", ErrCorruptSnapshot)
+	}
+	var h snapshotHeader
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, fmt.Errorf("%w: bad header: %v", ErrCorruptSnapshot, err)
+	}
+	if h.Version > snapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is newer than supported %d", h.Version, snapshotVersion)
+	}
+	if len(body) != h.Size {
+		return nil, fmt.Errorf("%w: body is %d bytes, header says %d", ErrCorruptSnapshot, len(body), h.Size)
+	}
+	sum := sha256.Sum256(body)
+	if h.Checksum != "sha256:"+hex.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	switch {
+	case bytes.HasPrefix(body, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+		}
+		defer zr.Close()
+		out, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+		}
+
+		return out, nil
+	case bytes.HasPrefix(body, zstdMagic):
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		out, err := dec.DecodeAll(body, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+		}
+
+		return out, nil
+	}
+
+	return body, nil
+}
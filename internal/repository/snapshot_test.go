@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testState(n int) State {
+	state := emptyState()
+	for i := 0; i < n; i++ {
+		state.Gauges["gauge"+strconv.Itoa(i)] = float64(i) / 4
+		state.Counters["counter"+strconv.Itoa(i)] = int64(i)
+	}
+
+	return state
+}
+
+func TestFileStateStore_Compression(t *testing.T) {
+	dir := t.TempDir()
+	in := testState(200)
+	sizes := make(map[Compression]int64)
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		path := filepath.Join(dir, string(c)+".json")
+		store := NewFileStateStore()
+		store.SetCompression(c)
+		if err := store.Save(path, in); err != nil {
+			t.Fatalf("%s: save: %v", c, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, _, _ := bytes.Cut(data, []byte{'\n'})
+		if !strings.Contains(string(header), `"compression":"`+string(c)+`"`) ||
+			!strings.Contains(string(header), `"checksum":"sha256:`) {
+			t.Fatalf("%s: unexpected header %s", c, header)
+		}
+		sizes[c] = int64(len(data))
+
+		// The compression is detected on load, whatever the store is set to
+		out, err := NewFileStateStore().Load(path)
+		if err != nil {
+			t.Fatalf("%s: load: %v", c, err)
+		}
+		if len(out.Gauges) != 200 || out.Gauges["gauge7"] != 1.75 || out.Counters["counter199"] != 199 {
+			t.Fatalf("%s: unexpected state", c)
+		}
+	}
+	if sizes[CompressionGzip] >= sizes[CompressionNone] || sizes[CompressionZstd] >= sizes[CompressionNone] {
+		t.Fatalf("compressed snapshots must be smaller: %v", sizes)
+	}
+}
+
+func TestFileStateStore_CorruptFallsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore()
+	store.SetCompression(CompressionGzip)
+	var recovered []error
+	store.SetErrorHandler(func(err error) { recovered = append(recovered, err) })
+
+	if err := store.Save(path, testState(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(path, testState(2)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, corrupt := range map[string][]byte{
+		"truncated":   data[:len(data)-10],
+		"flipped bit": append(append([]byte(nil), data[:len(data)-1]...), data[len(data)-1]^1),
+		"no body":     data[:bytes.IndexByte(data, '\n')],
+	} {
+		if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		recovered = nil
+		state, err := store.Load(path)
+		if err != nil {
+			t.Fatalf("%s: expected fallback, got %v", name, err)
+		}
+		if len(state.Gauges) != 1 {
+			t.Fatalf("%s: expected the previous snapshot, got %+v", name, state)
+		}
+		if len(recovered) != 1 || !errors.Is(recovered[0], ErrCorruptSnapshot) {
+			t.Fatalf("%s: expected reported corruption, got %v", name, recovered)
+		}
+	}
+
+	// Nothing to fall back to: a clear error, not an empty state
+	if err := os.Remove(prevPath(path)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(path); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Fatalf("expected corrupt snapshot error, got %v", err)
+	}
+}
+
+func TestFileStateStore_PrevWithoutLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore()
+
+	// Some file systems refuse hard links, the previous snapshot is copied then
+	linkFile = func(string, string) error { return errors.New("links not supported") }
+	defer func() { linkFile = os.Link }()
+	for i := 1; i <= 2; i++ {
+		if err := store.Save(path, testState(i)); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+
+	prev, err := store.Load(prevPath(path))
+	if err != nil {
+		t.Fatalf("load previous: %v", err)
+	}
+	if len(prev.Gauges) != 1 {
+		t.Fatalf("expected the first snapshot kept as previous, got %+v", prev)
+	}
+	if _, err := os.Stat(prevPath(path) + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary copy left behind: %v", err)
+	}
+	state, err := store.Load(path)
+	if err != nil || len(state.Gauges) != 2 {
+		t.Fatalf("expected the second snapshot in place, got %+v, %v", state, err)
+	}
+}
+
+func TestFileStateStore_CrashBeforeRename(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewFileStateStore()
+	if err := store.Save(path, testState(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop the next save right before the new snapshot is moved into place
+	crash := errors.New("crash")
+	renameFile = func(string, string) error { return crash }
+	defer func() { renameFile = os.Rename }()
+	if err := store.Save(path, testState(2)); !errors.Is(err, crash) {
+		t.Fatalf("expected the simulated crash, got %v", err)
+	}
+
+	var recovered error
+	store.SetErrorHandler(func(err error) { recovered = err })
+	state, err := store.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(state.Gauges) != 1 || recovered != nil {
+		t.Fatalf("expected the last complete snapshot in place, got %+v, %v", state, recovered)
+	}
+}
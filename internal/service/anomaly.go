@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// Anomaly detection defaults.
+const (
+	DefaultAnomalyAlpha  = 0.1
+	DefaultAnomalyK      = 3.0
+	DefaultAnomalyWarmup = 30
+	// AnomalySuffix names the synthetic gauge of a watched series:
+	// HeapAlloc{host="a"} is flagged in HeapAlloc_anomaly{host="a"}.
+	AnomalySuffix = "_anomaly"
+	// maxAnomalies bounds the list of recent anomalies.
+	maxAnomalies = 1000
+)
+
+// AnomalyConfig selects the gauges watched by the anomaly detector.
+type AnomalyConfig struct {
+	// Metrics are gauge names; every series of a name is tracked separately.
+	Metrics []string
+	// Alpha is the EWMA smoothing factor in (0, 1]; higher adapts faster.
+	Alpha float64
+	// K is the band width in standard deviations.
+	K float64
+	// Warmup is the number of points seen before a series can be flagged.
+	Warmup int
+}
+
+// Anomaly is a gauge point outside the band of its series.
+type Anomaly struct {
+	Series string    `json:"series"`
+	Metric string    `json:"metric"`
+	Time   time.Time `json:"time"`
+	Value  float64   `json:"value"`
+	// Mean and StdDev describe the series before this point.
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	ZScore float64 `json:"zscore"`
+}
+
+// ewma is the exponentially weighted mean and variance of a series.
+type ewma struct {
+	n        int
+	mean     float64
+	variance float64
+}
+
+type anomalyDetector struct {
+	mu      sync.Mutex
+	cfg     AnomalyConfig
+	metrics map[string]bool
+	series  map[string]*ewma
+	// recent is a ring of the last anomalies, next is the slot to overwrite.
+	recent []Anomaly
+	next   int
+}
+
+// SetAnomalyDetection enables the detector for the configured gauges.
+// Zero Alpha, K and Warmup take the defaults.
+func (ms *MetricsService) SetAnomalyDetection(cfg AnomalyConfig) {
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = DefaultAnomalyAlpha
+	}
+	if cfg.K <= 0 {
+		cfg.K = DefaultAnomalyK
+	}
+	if cfg.Warmup <= 0 {
+		cfg.Warmup = DefaultAnomalyWarmup
+	}
+	metrics := make(map[string]bool, len(cfg.Metrics))
+	for _, name := range cfg.Metrics {
+		metrics[name] = true
+	}
+	ms.anomalies = &anomalyDetector{
+		cfg:     cfg,
+		metrics: metrics,
+		series:  make(map[string]*ewma),
+	}
+}
+
+// Anomalies returns the recent anomalies, newest first, optionally only of one metric.
+func (ms *MetricsService) Anomalies(metric string) ([]Anomaly, error) {
+	d := ms.anomalies
+	if d == nil {
+		return nil, errors.New("anomaly detection disabled")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Anomaly, 0, len(d.recent))
+	for i := range d.recent {
+		// Walk the ring backwards from the newest entry
+		a := d.recent[(d.next-1-i+2*len(d.recent))%len(d.recent)]
+		if metric == "" || a.Metric == metric {
+			out = append(out, a)
+		}
+	}
+
+	return out, nil
+}
+
+// detectAnomaly feeds a gauge write to the detector and updates the synthetic
+// gauge of the series: 1 while the last point is anomalous, 0 otherwise.
+// Like history, detection never fails the write that fed it.
+// The synthetic gauge is written after the write that fed it, not with it:
+// a reader may see a /updates/ batch applied before its anomaly flags.
+func (ms *MetricsService) detectAnomaly(key string, v float64, now time.Time) {
+	d := ms.anomalies
+	if d == nil {
+		return
+	}
+	name, labels, err := models.ParseSeriesKey(key)
+	if err != nil || !d.metrics[name] || strings.HasSuffix(name, AnomalySuffix) {
+		return
+	}
+
+	flag := 0.0
+	if d.observe(key, name, v, now) {
+		flag = 1
+	}
+	_ = ms.storage.UpdateGauge(models.SeriesKey(name+AnomalySuffix, labels), flag)
+}
+
+// observe checks v against the band of the series, records the point when
+// it is anomalous, then moves the band. All of it runs under one lock, so
+// concurrent writes of a series are judged and listed in the same order.
+// Anomalous points are learned too, so a lasting level shift stops being
+// flagged after a while.
+func (d *anomalyDetector) observe(key, name string, v float64, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.series[key]
+	if !ok {
+		d.series[key] = &ewma{n: 1, mean: v}
+
+		return false
+	}
+
+	flagged := false
+	stddev := math.Sqrt(s.variance)
+	if s.n >= d.cfg.Warmup && stddev > 0 {
+		z := (v - s.mean) / stddev
+		if math.Abs(z) > d.cfg.K {
+			d.record(Anomaly{Series: key, Metric: name, Time: now, Value: v, Mean: s.mean, StdDev: stddev, ZScore: z})
+			flagged = true
+		}
+	}
+
+	// Incremental EWMA of mean and variance (Finch, 2009)
+	diff := v - s.mean
+	incr := d.cfg.Alpha * diff
+	s.mean += incr
+	s.variance = (1 - d.cfg.Alpha) * (s.variance + diff*incr)
+	s.n++
+
+	return flagged
+}
+
+// record adds a to the ring of recent anomalies; d.mu must be held.
+func (d *anomalyDetector) record(a Anomaly) {
+	if len(d.recent) < maxAnomalies {
+		d.recent = append(d.recent, a)
+		d.next = len(d.recent) % maxAnomalies
+
+		return
+	}
+	d.recent[d.next] = a
+	d.next = (d.next + 1) % maxAnomalies
+}
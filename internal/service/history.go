@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// maxHistoryPoints bounds the size of a downsampled history response.
+const maxHistoryPoints = 11000
+
+// HistoryStore keeps timestamped samples per series.
+type HistoryStore interface {
+	Append(series string, t time.Time, v float64) error
+	// Query returns the samples within [from, to], oldest first.
+	Query(series string, from, to time.Time) ([]models.Point, error)
+}
+
+// SetHistory injects the store recording every gauge and counter write.
+func (ms *MetricsService) SetHistory(history HistoryStore) {
+	ms.history = history
+}
+
+// SetHistoryErrorHandler sets a callback for samples the history store rejected.
+func (ms *MetricsService) SetHistoryErrorHandler(onError func(error)) {
+	ms.onHistoryError = onError
+}
+
+// History returns the samples of a gauge or counter series within [from, to].
+// With a positive step the range is split into windows of that length,
+// each represented by its last sample at the window start; empty windows are skipped.
+func (ms *MetricsService) History(mType, name string, from, to time.Time, step time.Duration) ([]models.Point, error) {
+	if ms.history == nil {
+		return nil, errors.New("history disabled")
+	}
+	if mType != models.Gauge && mType != models.Counter {
+		return nil, errors.New("bad metric type")
+	}
+	// Windows are aligned on milliseconds, the resolution of the samples
+	if to.Before(from) || step < 0 || (step > 0 && step < time.Millisecond) {
+		return nil, errors.New("bad value")
+	}
+	if step > 0 && to.Sub(from)/step > maxHistoryPoints {
+		return nil, errors.New("bad value")
+	}
+
+	points, err := ms.history.Query(historySeries(mType, name), from, to)
+	if err != nil {
+		return nil, err
+	}
+	if step == 0 || len(points) == 0 {
+		return points, nil
+	}
+
+	stepMs := step.Milliseconds()
+	start := from.UnixMilli()
+	out := make([]models.Point, 0, len(points))
+	for _, p := range points {
+		window := start + (p.Timestamp-start)/stepMs*stepMs
+		if n := len(out); n > 0 && out[n-1].Timestamp == window {
+			out[n-1].Value = p.Value
+
+			continue
+		}
+		out = append(out, models.Point{Timestamp: window, Value: p.Value})
+	}
+
+	return out, nil
+}
+
+// recordHistory appends v, the value of the series after a write.
+// History is best effort and never fails the write that fed it:
+// a rejected sample is only reported to the error handler.
+func (ms *MetricsService) recordHistory(mType, name string, v float64, t time.Time) {
+	if ms.history == nil {
+		return
+	}
+	series := historySeries(mType, name)
+	if err := ms.history.Append(series, t, v); err != nil && ms.onHistoryError != nil {
+		ms.onHistoryError(fmt.Errorf("record history of %s: %w", series, err))
+	}
+}
+
+func historySeries(mType, name string) string {
+	return mType + "/" + name
+}
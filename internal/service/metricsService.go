@@ -2,21 +2,69 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
 	"github.com/xGuthub/metrics-collection-service/internal/repository"
+	"github.com/xGuthub/metrics-collection-service/internal/sign"
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
 )
 
 type Storage interface {
-	UpdateGauge(name string, value float64)
-	UpdateCounter(name string, delta int64)
-	GetGauge(name string) (float64, bool)
-	GetCounter(name string) (int64, bool)
-	AllGauges() map[string]float64
-	AllCounters() map[string]int64
+	UpdateGauge(name string, value float64) error
+	// UpdateCounter adds delta and returns the resulting total.
+	UpdateCounter(name string, delta int64) (int64, error)
+	// Reads report a missing metric with false, and failures with an error.
+	GetGauge(name string) (float64, bool, error)
+	GetCounter(name string) (int64, bool, error)
+	AllGauges() (map[string]float64, error)
+	AllCounters() (map[string]int64, error)
+	// UpdateBatch applies gauges and counter deltas at once and returns
+	// the resulting totals of the counters in the batch.
+	UpdateBatch(gauges map[string]float64, counters map[string]int64) (map[string]int64, error)
+}
+
+// HistogramStorage is implemented by storages able to keep histograms.
+type HistogramStorage interface {
+	// ObserveHistogram creates a missing histogram with bounds and adds value.
+	// Nil bounds observe into an existing histogram only.
+	ObserveHistogram(name string, bounds []float64, value float64) error
+	GetHistogram(name string) (*sketch.Histogram, bool, error)
+	AllHistograms() (map[string]*sketch.Histogram, error)
+	RestoreHistograms(histograms map[string]*sketch.Histogram) error
+}
+
+// Pinger is implemented by storages backed by an external database.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ItemError describes why a single element of a batch was rejected.
+type ItemError struct {
+	Index int    `json:"index"`
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// BatchError is returned by UpdateMetrics when at least one element is invalid.
+// Nothing from the batch is applied in that case.
+type BatchError struct {
+	Items []ItemError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Items))
+	for _, it := range e.Items {
+		msgs = append(msgs, fmt.Sprintf("#%d %q: %s", it.Index, it.ID, it.Error))
+	}
+
+	return "invalid batch: " + strings.Join(msgs, "; ")
 }
 
 type MetricsService struct {
@@ -26,11 +74,23 @@ type MetricsService struct {
 	storeInterval time.Duration
 	restore       bool
 	stateStore    repository.StateStore
+	// signKey enables verification and population of per-metric hashes.
+	signKey string
+	// defaultBuckets are used for histograms created without declared buckets.
+	defaultBuckets []float64
+	summaryMaxAge  time.Duration
+	setPrecision   uint8
+	history        HistoryStore
+	onHistoryError func(error)
+	anomalies      *anomalyDetector
 }
 
 func NewMetricsService(memStorage Storage) *MetricsService {
 	return &MetricsService{
-		storage: memStorage,
+		storage:        memStorage,
+		defaultBuckets: sketch.DefaultBounds,
+		summaryMaxAge:  DefaultSummaryMaxAge,
+		setPrecision:   sketch.DefaultPrecision,
 	}
 }
 
@@ -53,30 +113,138 @@ func (ms *MetricsService) SetStateStore(store repository.StateStore) {
 	ms.stateStore = store
 }
 
-func (ms *MetricsService) AllGauges() map[string]float64 {
+// SetSignKey sets the shared key used to verify and sign per-metric hashes.
+func (ms *MetricsService) SetSignKey(key string) {
+	ms.signKey = key
+}
+
+// VerifyMetricHash checks the Hash field of m when signing is enabled.
+// Metrics without a hash are rejected then.
+func (ms *MetricsService) VerifyMetricHash(m models.Metrics) error {
+	if ms.signKey == "" {
+		return nil
+	}
+	if m.Hash == "" {
+		return errors.New("missing hash")
+	}
+	if !sign.VerifyMetric(ms.signKey, m) {
+		return errors.New("hash mismatch")
+	}
+
+	return nil
+}
+
+// SignMetric fills the Hash field of m when signing is enabled.
+func (ms *MetricsService) SignMetric(m *models.Metrics) {
+	if ms.signKey == "" {
+		return
+	}
+	m.Hash = sign.Metric(ms.signKey, *m)
+}
+
+// SetDefaultBuckets sets the bucket bounds of histograms whose first write
+// does not declare any.
+func (ms *MetricsService) SetDefaultBuckets(bounds []float64) {
+	ms.defaultBuckets = bounds
+}
+
+func (ms *MetricsService) AllGauges() (map[string]float64, error) {
 	return ms.storage.AllGauges()
 }
 
-func (ms *MetricsService) AllCounters() map[string]int64 {
+func (ms *MetricsService) AllCounters() (map[string]int64, error) {
 	return ms.storage.AllCounters()
 }
 
+// UnsupportedMetricTypes returns the metric types the storage cannot keep.
+// Writes of these types are rejected with "bad metric type".
+func (ms *MetricsService) UnsupportedMetricTypes() []string {
+	var types []string
+	if _, ok := ms.storage.(HistogramStorage); !ok {
+		types = append(types, models.Histogram)
+	}
+	if _, ok := ms.storage.(SummaryStorage); !ok {
+		types = append(types, models.Summary)
+	}
+	if _, ok := ms.storage.(SetStorage); !ok {
+		types = append(types, models.Set)
+	}
+
+	return types
+}
+
+// AllHistograms returns copies of all histograms, or nil when the storage
+// does not support them.
+func (ms *MetricsService) AllHistograms() (map[string]*sketch.Histogram, error) {
+	hs, ok := ms.storage.(HistogramStorage)
+	if !ok {
+		return nil, nil
+	}
+
+	return hs.AllHistograms()
+}
+
+// GetHistogram returns a copy of the named histogram.
+func (ms *MetricsService) GetHistogram(name string) (*sketch.Histogram, error) {
+	hs, ok := ms.storage.(HistogramStorage)
+	if !ok {
+		return nil, errors.New("bad metric type")
+	}
+	h, exists, err := hs.GetHistogram(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("not found")
+	}
+
+	return h, nil
+}
+
 func (ms *MetricsService) GetMetric(mType, name string) (string, error) {
 	var val string
 
 	switch mType {
 	case "gauge":
-		v, exists := ms.storage.GetGauge(name)
+		v, exists, err := ms.storage.GetGauge(name)
+		if err != nil {
+			return "", err
+		}
 		if !exists {
 			return "", errors.New("not found")
 		}
 		val = strconv.FormatFloat(v, 'g', -1, 64)
 	case "counter":
-		v, exists := ms.storage.GetCounter(name)
+		v, exists, err := ms.storage.GetCounter(name)
+		if err != nil {
+			return "", err
+		}
 		if !exists {
 			return "", errors.New("not found")
 		}
 		val = strconv.FormatInt(v, 10)
+	case models.Histogram:
+		h, err := ms.GetHistogram(name)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(h)
+		if err != nil {
+			return "", fmt.Errorf("encode histogram %q: %w", name, err)
+		}
+		val = string(data)
+	case models.Summary:
+		v, err := ms.SummaryQuantile(name, 0.5)
+		if err != nil {
+			return "", err
+		}
+		val = strconv.FormatFloat(v, 'g', -1, 64)
+	case models.Set:
+		v, err := ms.SetCardinality(name)
+		if err != nil {
+			return "", err
+		}
+		val = strconv.FormatUint(v, 10)
 	default:
 		return "", errors.New("bad metric type")
 	}
@@ -91,13 +259,39 @@ func (ms *MetricsService) UpdateMetric(mType, name, val string) error {
 		if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
 			return errors.New("bad value")
 		}
-		ms.storage.UpdateGauge(name, val)
+		if err := ms.storage.UpdateGauge(name, val); err != nil {
+			return fmt.Errorf("update gauge %q: %w", name, err)
+		}
+		now := time.Now()
+		ms.recordHistory(models.Gauge, name, val, now)
+		ms.detectAnomaly(name, val, now)
 	case "counter":
 		delta, err := strconv.ParseInt(val, 10, 64)
 		if err != nil {
 			return errors.New("bad value")
 		}
-		ms.storage.UpdateCounter(name, delta)
+		total, err := ms.storage.UpdateCounter(name, delta)
+		if err != nil {
+			return fmt.Errorf("update counter %q: %w", name, err)
+		}
+		ms.recordHistory(models.Counter, name, float64(total), time.Now())
+	case models.Histogram:
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return errors.New("bad value")
+		}
+
+		return ms.ObserveHistogram(name, nil, v)
+	case models.Summary:
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return errors.New("bad value")
+		}
+
+		return ms.ObserveSummary(name, v)
+	case models.Set:
+		// The path value is the member itself
+		return ms.AddSetMember(name, val)
 	default:
 		return errors.New("bad metric type")
 	}
@@ -108,6 +302,131 @@ func (ms *MetricsService) UpdateMetric(mType, name, val string) error {
 	return nil
 }
 
+// ObserveHistogram adds value to the named histogram. Buckets declared on the
+// first write fix the bounds of the histogram, later writes may omit them but
+// must not declare different ones. Without declared buckets the defaults apply.
+func (ms *MetricsService) ObserveHistogram(name string, buckets []float64, value float64) error {
+	hs, ok := ms.storage.(HistogramStorage)
+	if !ok {
+		return errors.New("bad metric type")
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return errors.New("bad value")
+	}
+	if buckets != nil {
+		if err := sketch.ValidateBounds(buckets); err != nil {
+			return errors.New("bad value")
+		}
+	}
+
+	err := hs.ObserveHistogram(name, buckets, value)
+	if errors.Is(err, repository.ErrHistogramNotFound) {
+		err = hs.ObserveHistogram(name, ms.defaultBuckets, value)
+	}
+	switch {
+	case errors.Is(err, repository.ErrBucketsMismatch):
+		return errors.New("bad value")
+	case err != nil:
+		return fmt.Errorf("observe histogram %q: %w", name, err)
+	}
+
+	if ms.storeInterval == 0 && ms.persistPath != "" {
+		_ = ms.SaveState()
+	}
+	return nil
+}
+
+// UpdateMetrics validates every element of the batch and applies all of them
+// in one storage call. If any element is invalid, nothing is applied and
+// a *BatchError listing the rejected elements is returned.
+// Batches carry gauges and counters only.
+func (ms *MetricsService) UpdateMetrics(batch []models.Metrics) error {
+	gauges := make(map[string]float64)
+	counters := make(map[string]int64)
+
+	var batchErr BatchError
+	for i, m := range batch {
+		if err := ms.validateMetric(m); err != nil {
+			batchErr.Items = append(batchErr.Items, ItemError{Index: i, ID: m.ID, Error: err.Error()})
+
+			continue
+		}
+		switch m.MType {
+		case models.Gauge:
+			gauges[m.Key()] = *m.Value
+		case models.Counter:
+			counters[m.Key()] += *m.Delta
+		}
+	}
+	if len(batchErr.Items) > 0 {
+		return &batchErr
+	}
+
+	totals, err := ms.storage.UpdateBatch(gauges, counters)
+	if err != nil {
+		return fmt.Errorf("update batch: %w", err)
+	}
+	now := time.Now()
+	for name, v := range gauges {
+		ms.recordHistory(models.Gauge, name, v, now)
+		ms.detectAnomaly(name, v, now)
+	}
+	for name, total := range totals {
+		ms.recordHistory(models.Counter, name, float64(total), now)
+	}
+
+	if ms.storeInterval == 0 && ms.persistPath != "" {
+		_ = ms.SaveState()
+	}
+	return nil
+}
+
+func (ms *MetricsService) validateMetric(m models.Metrics) error {
+	if err := ValidateSeries(m); err != nil {
+		return err
+	}
+	switch m.MType {
+	case models.Gauge:
+		if m.Value == nil || math.IsNaN(*m.Value) || math.IsInf(*m.Value, 0) {
+			return errors.New("bad value")
+		}
+	case models.Counter:
+		if m.Delta == nil {
+			return errors.New("bad value")
+		}
+	default:
+		return errors.New("bad metric type")
+	}
+
+	return ms.VerifyMetricHash(m)
+}
+
+// Ping checks connectivity of the underlying storage.
+// Storages without an external connection do not support it.
+func (ms *MetricsService) Ping(ctx context.Context) error {
+	p, ok := ms.storage.(Pinger)
+	if !ok {
+		return errors.New("storage does not support ping")
+	}
+
+	return p.Ping(ctx)
+}
+
+// ValidateSeries checks the series identity of m: a non-empty name without
+// label braces and valid label names.
+func ValidateSeries(m models.Metrics) error {
+	if m.ID == "" || strings.ContainsAny(m.ID, "{}") {
+		return errors.New("bad value")
+	}
+	for name := range m.Labels {
+		if !models.ValidLabelName(name) {
+			return errors.New("bad value")
+		}
+	}
+
+	return nil
+}
+
 // StartAutoSave launches periodic persistence if StoreInterval > 0.
 // onError is optional; if provided, it receives save errors.
 func (ms *MetricsService) StartAutoSave(ctx context.Context, onError func(error)) {
@@ -135,7 +454,43 @@ func (ms *MetricsService) SaveState() error {
 	if ms.persistPath == "" || ms.stateStore == nil {
 		return nil
 	}
-	return ms.stateStore.Save(ms.persistPath, ms.storage.AllGauges(), ms.storage.AllCounters())
+	state, err := ms.currentState()
+	if err != nil {
+		return err
+	}
+	return ms.stateStore.Save(ms.persistPath, state)
+}
+
+// currentState collects every metric of the storage for a snapshot.
+func (ms *MetricsService) currentState() (repository.State, error) {
+	gauges, err := ms.storage.AllGauges()
+	if err != nil {
+		return repository.State{}, err
+	}
+	counters, err := ms.storage.AllCounters()
+	if err != nil {
+		return repository.State{}, err
+	}
+	histograms, err := ms.AllHistograms()
+	if err != nil {
+		return repository.State{}, err
+	}
+	summaries, err := ms.allSummaries()
+	if err != nil {
+		return repository.State{}, err
+	}
+	sets, err := ms.allSets()
+	if err != nil {
+		return repository.State{}, err
+	}
+
+	return repository.State{
+		Gauges:     gauges,
+		Counters:   counters,
+		Histograms: histograms,
+		Summaries:  summaries,
+		Sets:       sets,
+	}, nil
 }
 
 // RestoreState loads persisted state via injected repository.
@@ -143,15 +498,39 @@ func (ms *MetricsService) RestoreState() error {
 	if !ms.restore || ms.persistPath == "" || ms.stateStore == nil {
 		return nil
 	}
-	gauges, counters, err := ms.stateStore.Load(ms.persistPath)
+	state, err := ms.stateStore.Load(ms.persistPath)
 	if err != nil {
 		return err
 	}
-	for k, v := range gauges {
-		ms.storage.UpdateGauge(k, v)
+	if _, err := ms.storage.UpdateBatch(state.Gauges, state.Counters); err != nil {
+		return err
 	}
-	for k, v := range counters {
-		ms.storage.UpdateCounter(k, v)
+	if len(state.Histograms) > 0 {
+		hs, ok := ms.storage.(HistogramStorage)
+		if !ok {
+			return errors.New("storage does not support histograms")
+		}
+		if err := hs.RestoreHistograms(state.Histograms); err != nil {
+			return err
+		}
+	}
+	if len(state.Summaries) > 0 {
+		ss, ok := ms.storage.(SummaryStorage)
+		if !ok {
+			return errors.New("storage does not support summaries")
+		}
+		if err := ss.RestoreSummaries(state.Summaries); err != nil {
+			return err
+		}
+	}
+	if len(state.Sets) > 0 {
+		ss, ok := ms.storage.(SetStorage)
+		if !ok {
+			return errors.New("storage does not support sets")
+		}
+		if err := ss.RestoreSets(state.Sets); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+)
+
+// SetStorage is implemented by storages able to keep unique-count sets.
+type SetStorage interface {
+	// AddSetMember creates a missing set with the given precision and adds member.
+	AddSetMember(name string, precision uint8, member string) error
+	GetSet(name string) (*sketch.HyperLogLog, bool, error)
+	AllSets() (map[string]*sketch.HyperLogLog, error)
+	RestoreSets(sets map[string]*sketch.HyperLogLog) error
+}
+
+// SetSetPrecision sets the HyperLogLog precision of sets created from now on.
+func (ms *MetricsService) SetSetPrecision(precision uint8) {
+	ms.setPrecision = precision
+}
+
+// AddSetMember adds member to the named set.
+func (ms *MetricsService) AddSetMember(name, member string) error {
+	ss, ok := ms.storage.(SetStorage)
+	if !ok {
+		return errors.New("bad metric type")
+	}
+	if member == "" {
+		return errors.New("bad value")
+	}
+	if err := ss.AddSetMember(name, ms.setPrecision, member); err != nil {
+		return fmt.Errorf("add set member %q: %w", name, err)
+	}
+
+	if ms.storeInterval == 0 && ms.persistPath != "" {
+		_ = ms.SaveState()
+	}
+	return nil
+}
+
+// SetCardinality returns the estimated number of distinct members of the named set.
+func (ms *MetricsService) SetCardinality(name string) (uint64, error) {
+	ss, ok := ms.storage.(SetStorage)
+	if !ok {
+		return 0, errors.New("bad metric type")
+	}
+	hll, exists, err := ss.GetSet(name)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, errors.New("not found")
+	}
+
+	return hll.Estimate(), nil
+}
+
+// AllSetCardinalities returns the estimate of every set, or nil when the
+// storage does not support sets.
+func (ms *MetricsService) AllSetCardinalities() (map[string]uint64, error) {
+	ss, ok := ms.storage.(SetStorage)
+	if !ok {
+		return nil, nil
+	}
+	all, err := ss.AllSets()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]uint64, len(all))
+	for name, hll := range all {
+		out[name] = hll.Estimate()
+	}
+
+	return out, nil
+}
+
+func (ms *MetricsService) allSets() (map[string]*sketch.HyperLogLog, error) {
+	ss, ok := ms.storage.(SetStorage)
+	if !ok {
+		return nil, nil
+	}
+
+	return ss.AllSets()
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xGuthub/metrics-collection-service/internal/repository"
+)
+
+// GenerationStore is implemented by state stores keeping point-in-time
+// generations of the state file.
+type GenerationStore interface {
+	// Generations lists the generations of the state file, newest first.
+	Generations(path string) ([]repository.Generation, error)
+	LoadGeneration(path, id string) (repository.State, error)
+	// SaveGeneration saves the state file and keeps it as a generation
+	// regardless of the generation interval.
+	SaveGeneration(path string, state repository.State) error
+}
+
+// StateReplacer is implemented by storages able to swap their whole content.
+type StateReplacer interface {
+	ReplaceState(state repository.State) error
+}
+
+// SnapshotGenerations lists the kept generations of the state file, newest first.
+func (ms *MetricsService) SnapshotGenerations() ([]repository.Generation, error) {
+	gs, ok := ms.stateStore.(GenerationStore)
+	if !ok || ms.persistPath == "" {
+		return nil, errors.New("snapshots disabled")
+	}
+	gens, err := gs.Generations(ms.persistPath)
+	if err != nil {
+		return nil, fmt.Errorf("list generations: %w", err)
+	}
+	if gens == nil {
+		gens = []repository.Generation{}
+	}
+
+	return gens, nil
+}
+
+// RestoreGeneration replaces all metrics with the content of a generation and
+// saves it as the current state and the newest generation, even when the last
+// generation is more recent than the generation interval.
+func (ms *MetricsService) RestoreGeneration(id string) error {
+	gs, ok := ms.stateStore.(GenerationStore)
+	if !ok || ms.persistPath == "" {
+		return errors.New("snapshots disabled")
+	}
+	rs, ok := ms.storage.(StateReplacer)
+	if !ok {
+		return errors.New("snapshots disabled")
+	}
+	state, err := gs.LoadGeneration(ms.persistPath, id)
+	switch {
+	case errors.Is(err, repository.ErrGenerationNotFound):
+		return errors.New("not found")
+	case errors.Is(err, repository.ErrCorruptSnapshot):
+		return errors.New("corrupt snapshot")
+	case err != nil:
+		return fmt.Errorf("load generation %q: %w", id, err)
+	}
+	if err := rs.ReplaceState(state); err != nil {
+		return fmt.Errorf("replace state: %w", err)
+	}
+	// Writes may have landed since the replace, so the storage is read again
+	current, err := ms.currentState()
+	if err != nil {
+		return err
+	}
+
+	return gs.SaveGeneration(ms.persistPath, current)
+}
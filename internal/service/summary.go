@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/xGuthub/metrics-collection-service/internal/sketch"
+)
+
+// DefaultSummaryMaxAge is the sliding window of summaries unless configured.
+const DefaultSummaryMaxAge = 10 * time.Minute
+
+// SummaryStorage is implemented by storages able to keep summaries.
+type SummaryStorage interface {
+	// ObserveSummary creates a missing summary with the maxAge window and adds value.
+	ObserveSummary(name string, maxAge time.Duration, value float64, now time.Time) error
+	GetSummary(name string) (*sketch.Summary, bool, error)
+	AllSummaries() (map[string]*sketch.Summary, error)
+	RestoreSummaries(summaries map[string]*sketch.Summary) error
+}
+
+// SetSummaryMaxAge sets the sliding window of summaries created from now on.
+func (ms *MetricsService) SetSummaryMaxAge(maxAge time.Duration) {
+	ms.summaryMaxAge = maxAge
+}
+
+// ObserveSummary adds value to the named summary.
+func (ms *MetricsService) ObserveSummary(name string, value float64) error {
+	ss, ok := ms.storage.(SummaryStorage)
+	if !ok {
+		return errors.New("bad metric type")
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return errors.New("bad value")
+	}
+	if err := ss.ObserveSummary(name, ms.summaryMaxAge, value, time.Now()); err != nil {
+		return fmt.Errorf("observe summary %q: %w", name, err)
+	}
+
+	if ms.storeInterval == 0 && ms.persistPath != "" {
+		_ = ms.SaveState()
+	}
+	return nil
+}
+
+// SummaryQuantile estimates the q-quantile of the named summary over its
+// current window. It returns NaN when the window holds no observations.
+func (ms *MetricsService) SummaryQuantile(name string, q float64) (float64, error) {
+	if math.IsNaN(q) || q < 0 || q > 1 {
+		return 0, errors.New("bad value")
+	}
+	ss, ok := ms.storage.(SummaryStorage)
+	if !ok {
+		return 0, errors.New("bad metric type")
+	}
+	sm, exists, err := ss.GetSummary(name)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, errors.New("not found")
+	}
+
+	return sm.Snapshot(time.Now()).Quantile(q), nil
+}
+
+// SummarySnapshots returns the current window of every summary merged into
+// a single digest, or nil when the storage does not support summaries.
+func (ms *MetricsService) SummarySnapshots() (map[string]*sketch.TDigest, error) {
+	ss, ok := ms.storage.(SummaryStorage)
+	if !ok {
+		return nil, nil
+	}
+	now := time.Now()
+	all, err := ss.AllSummaries()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*sketch.TDigest, len(all))
+	for name, sm := range all {
+		out[name] = sm.Snapshot(now)
+	}
+
+	return out, nil
+}
+
+func (ms *MetricsService) allSummaries() (map[string]*sketch.Summary, error) {
+	ss, ok := ms.storage.(SummaryStorage)
+	if !ok {
+		return nil, nil
+	}
+
+	return ss.AllSummaries()
+}
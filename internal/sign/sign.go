@@ -0,0 +1,74 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+// Header is the HTTP header carrying the hex-encoded HMAC-SHA256 of the body.
+const Header = "HashSHA256"
+
+// Sum returns the hex-encoded HMAC-SHA256 of data keyed with key.
+func Sum(key string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sum is a valid signature of data.
+func Verify(key string, data []byte, sum string) bool {
+	got, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Metric returns the signature of a single metric.
+// The signed string is "<series key>:<type>:<value>", value is empty when not set.
+// A histogram state is signed as "<count>/<sum>", a set update by its member.
+// Declared buckets follow as ":<b1>,<b2>,...". The series key is the bare ID
+// for unlabeled metrics.
+func Metric(key string, m models.Metrics) string {
+	return Sum(key, []byte(metricPayload(m)))
+}
+
+// VerifyMetric reports whether m.Hash is a valid signature of m.
+func VerifyMetric(key string, m models.Metrics) bool {
+	return Verify(key, []byte(metricPayload(m)), m.Hash)
+}
+
+func metricPayload(m models.Metrics) string {
+	var val string
+	switch {
+	case m.Delta != nil:
+		val = strconv.FormatInt(*m.Delta, 10)
+	case m.Value != nil:
+		val = strconv.FormatFloat(*m.Value, 'g', -1, 64)
+	case m.Histogram != nil:
+		val = strconv.FormatUint(m.Histogram.Count, 10) + "/" + strconv.FormatFloat(m.Histogram.Sum, 'g', -1, 64)
+	case m.Member != "":
+		val = m.Member
+	}
+
+	payload := fmt.Sprintf("%s:%s:%s", m.Key(), m.MType, val)
+	if len(m.Buckets) > 0 {
+		bounds := make([]string, len(m.Buckets))
+		for i, b := range m.Buckets {
+			bounds[i] = strconv.FormatFloat(b, 'g', -1, 64)
+		}
+		payload += ":" + strings.Join(bounds, ",")
+	}
+
+	return payload
+}
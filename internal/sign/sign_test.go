@@ -0,0 +1,61 @@
+package sign
+
+import (
+	"testing"
+
+	models "github.com/xGuthub/metrics-collection-service/internal/model"
+)
+
+func TestSumAndVerify(t *testing.T) {
+	data := []byte(`{"id":"a","type":"gauge","value":1}`)
+	sum := Sum("secret", data)
+
+	if !Verify("secret", data, sum) {
+		t.Fatalf("expected signature to verify")
+	}
+	if Verify("other", data, sum) {
+		t.Fatalf("signature verified with a wrong key")
+	}
+	if Verify("secret", append(data, ' '), sum) {
+		t.Fatalf("signature verified for modified data")
+	}
+	if Verify("secret", data, "not-hex") {
+		t.Fatalf("malformed signature verified")
+	}
+}
+
+func TestMetric(t *testing.T) {
+	v := 1.5
+	m := models.Metrics{ID: "temp", MType: models.Gauge, Value: &v}
+	m.Hash = Metric("secret", m)
+
+	if !VerifyMetric("secret", m) {
+		t.Fatalf("expected metric hash to verify")
+	}
+
+	v2 := 2.5
+	m.Value = &v2
+	if VerifyMetric("secret", m) {
+		t.Fatalf("metric hash verified after value change")
+	}
+}
+
+func TestMetric_Buckets(t *testing.T) {
+	v := 0.3
+	m := models.Metrics{ID: "lat", MType: models.Histogram, Value: &v, Buckets: []float64{0.1, 0.5, 1}}
+	m.Hash = Metric("secret", m)
+
+	if !VerifyMetric("secret", m) {
+		t.Fatalf("expected histogram hash to verify")
+	}
+
+	// Buckets fix the histogram layout on its first write, so they are signed
+	m.Buckets = []float64{0.1, 0.2}
+	if VerifyMetric("secret", m) {
+		t.Fatalf("histogram hash verified after buckets change")
+	}
+	m.Buckets = nil
+	if VerifyMetric("secret", m) {
+		t.Fatalf("histogram hash verified after buckets removal")
+	}
+}
@@ -0,0 +1,96 @@
+package sketch
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+// DefaultBounds are general purpose latency buckets in seconds.
+var DefaultBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram accumulates observations into fixed buckets.
+//
+// Bounds are the sorted upper bounds of the buckets. Counts has one more
+// element than Bounds: Counts[i] is the number of observations v with
+// Bounds[i-1] < v <= Bounds[i], the last element counts values above every bound.
+// Counts are per bucket, not cumulative.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// NewHistogram creates an empty histogram with the given bucket upper bounds.
+func NewHistogram(bounds []float64) (*Histogram, error) {
+	if err := ValidateBounds(bounds); err != nil {
+		return nil, err
+	}
+
+	return &Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}, nil
+}
+
+// ValidateBounds checks that bounds are finite and strictly increasing.
+func ValidateBounds(bounds []float64) error {
+	if len(bounds) == 0 {
+		return errors.New("histogram needs at least one bucket")
+	}
+	for i, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return errors.New("histogram bucket bounds must be finite")
+		}
+		if i > 0 && b <= bounds[i-1] {
+			return errors.New("histogram bucket bounds must be strictly increasing")
+		}
+	}
+
+	return nil
+}
+
+// Observe adds a single value.
+func (h *Histogram) Observe(v float64) {
+	// First bucket whose upper bound is >= v; len(Bounds) is the overflow bucket
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// SameBounds reports whether the histogram uses exactly the given bounds.
+func (h *Histogram) SameBounds(bounds []float64) bool {
+	return slices.Equal(h.Bounds, bounds)
+}
+
+// Clone returns a deep copy.
+func (h *Histogram) Clone() *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
+}
+
+// Validate checks the consistency of a histogram restored from outside.
+func (h *Histogram) Validate() error {
+	if err := ValidateBounds(h.Bounds); err != nil {
+		return err
+	}
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return errors.New("histogram counts do not match its buckets")
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != h.Count {
+		return errors.New("histogram count does not match its buckets")
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package sketch
+
+import "testing"
+
+func TestHistogram_Observe(t *testing.T) {
+	h, err := NewHistogram([]float64{0.1, 1, 10})
+	if err != nil {
+		t.Fatalf("new histogram: %v", err)
+	}
+
+	for _, v := range []float64{0.05, 0.1, 0.5, 1, 5, 100, -3} {
+		h.Observe(v)
+	}
+
+	// Upper bounds are inclusive
+	want := []uint64{3, 2, 1, 1}
+	for i, c := range want {
+		if h.Counts[i] != c {
+			t.Fatalf("bucket %d: got %d, want %d (counts %v)", i, h.Counts[i], c, h.Counts)
+		}
+	}
+	if h.Count != 7 || h.Sum != 103.65 {
+		t.Fatalf("unexpected count/sum: %d %v", h.Count, h.Sum)
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+}
+
+func TestHistogram_Bounds(t *testing.T) {
+	for _, bad := range [][]float64{nil, {1, 1}, {2, 1}} {
+		if _, err := NewHistogram(bad); err == nil {
+			t.Fatalf("expected error for bounds %v", bad)
+		}
+	}
+
+	h, _ := NewHistogram([]float64{1, 2})
+	if !h.SameBounds([]float64{1, 2}) || h.SameBounds([]float64{1, 3}) {
+		t.Fatalf("SameBounds misreports")
+	}
+
+	c := h.Clone()
+	c.Observe(1)
+	if h.Count != 0 {
+		t.Fatalf("clone shares state with the original")
+	}
+
+	broken := &Histogram{Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 1}
+	if err := broken.Validate(); err == nil {
+		t.Fatalf("expected validation error for inconsistent count")
+	}
+}
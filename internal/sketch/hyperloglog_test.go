@@ -0,0 +1,70 @@
+package sketch
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHyperLogLog_Estimate(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		h, err := NewHyperLogLog(DefaultPrecision)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			member := "user-" + strconv.Itoa(i)
+			// Duplicates must not change the estimate
+			h.Add(member)
+			h.Add(member)
+		}
+		got := float64(h.Estimate())
+		if math.Abs(got-float64(n)) > 0.03*float64(n)+1 {
+			t.Fatalf("n=%d: estimate %v is off by more than 3%%", n, got)
+		}
+	}
+}
+
+func TestHyperLogLog_MergeAndRoundTrip(t *testing.T) {
+	a, _ := NewHyperLogLog(10)
+	b, _ := NewHyperLogLog(10)
+	for i := 0; i < 500; i++ {
+		a.Add("a" + strconv.Itoa(i))
+		b.Add("b" + strconv.Itoa(i))
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if got := float64(a.Estimate()); math.Abs(got-1000) > 60 {
+		t.Fatalf("merged estimate: got %v", got)
+	}
+
+	other, _ := NewHyperLogLog(12)
+	if err := a.Merge(other); err == nil {
+		t.Fatalf("expected precision mismatch error")
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var restored HyperLogLog
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := restored.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if restored.Estimate() != a.Estimate() {
+		t.Fatalf("restored estimate differs")
+	}
+
+	if _, err := NewHyperLogLog(MaxPrecision + 1); err == nil {
+		t.Fatalf("expected error for precision out of range")
+	}
+	truncated := &HyperLogLog{Precision: 10, Registers: make([]byte, 10)}
+	if err := truncated.Validate(); err == nil {
+		t.Fatalf("expected error for truncated registers")
+	}
+}
@@ -0,0 +1,100 @@
+package sketch
+
+import (
+	"errors"
+	"time"
+)
+
+// SummaryWindows is the number of age windows a summary rotates through.
+// More windows make expiry smoother at the cost of memory.
+const SummaryWindows = 5
+
+// Summary estimates quantiles over a sliding time window.
+//
+// Observations go into consecutive windows of MaxAge/SummaryWindows each;
+// windows ending before now-MaxAge are dropped. Queries merge the remaining
+// windows, so they cover between MaxAge·(1-1/SummaryWindows) and MaxAge.
+type Summary struct {
+	MaxAge      time.Duration   `json:"max_age"`
+	Compression float64         `json:"compression"`
+	Windows     []SummaryWindow `json:"windows"`
+}
+
+// SummaryWindow holds the observations made since Start.
+type SummaryWindow struct {
+	Start  time.Time `json:"start"`
+	Digest *TDigest  `json:"digest"`
+}
+
+// NewSummary creates an empty summary; non-positive compression uses the default.
+func NewSummary(maxAge time.Duration, compression float64) (*Summary, error) {
+	if maxAge <= 0 {
+		return nil, errors.New("summary max age must be positive")
+	}
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+
+	return &Summary{MaxAge: maxAge, Compression: compression}, nil
+}
+
+// Observe adds v at time now.
+func (s *Summary) Observe(v float64, now time.Time) {
+	s.expire(now)
+	span := s.MaxAge / SummaryWindows
+	if n := len(s.Windows); n == 0 || !now.Before(s.Windows[n-1].Start.Add(span)) {
+		s.Windows = append(s.Windows, SummaryWindow{Start: now, Digest: NewTDigest(s.Compression)})
+	}
+	s.Windows[len(s.Windows)-1].Digest.Add(v)
+}
+
+// Snapshot merges the windows that are still current at now.
+func (s *Summary) Snapshot(now time.Time) *TDigest {
+	s.expire(now)
+	out := NewTDigest(s.Compression)
+	for _, w := range s.Windows {
+		out.Merge(w.Digest)
+	}
+
+	return out
+}
+
+// Clone returns a deep copy.
+func (s *Summary) Clone() *Summary {
+	c := &Summary{MaxAge: s.MaxAge, Compression: s.Compression}
+	c.Windows = make([]SummaryWindow, len(s.Windows))
+	for i, w := range s.Windows {
+		c.Windows[i] = SummaryWindow{Start: w.Start, Digest: w.Digest.Clone()}
+	}
+
+	return c
+}
+
+// Validate checks the consistency of a summary restored from outside.
+func (s *Summary) Validate() error {
+	if s.MaxAge <= 0 {
+		return errors.New("summary max age must be positive")
+	}
+	for _, w := range s.Windows {
+		if w.Digest == nil {
+			return errors.New("summary window has no digest")
+		}
+		if err := w.Digest.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Summary) expire(now time.Time) {
+	span := s.MaxAge / SummaryWindows
+	cutoff := now.Add(-s.MaxAge)
+	i := 0
+	for i < len(s.Windows) && !s.Windows[i].Start.Add(span).After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		s.Windows = append(s.Windows[:0], s.Windows[i:]...)
+	}
+}
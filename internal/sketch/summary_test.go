@@ -0,0 +1,59 @@
+package sketch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummary_Window(t *testing.T) {
+	s, err := NewSummary(10*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("new summary: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Old high values, then recent low ones
+	for i := 0; i < 100; i++ {
+		s.Observe(1000, start)
+	}
+	for i := 0; i < 100; i++ {
+		s.Observe(1, start.Add(5*time.Minute))
+	}
+	if got := s.Snapshot(start.Add(5 * time.Minute)).Count; got != 200 {
+		t.Fatalf("both windows must be current, got count %v", got)
+	}
+
+	// The first window ends at +2m and leaves the 10m window at +12m
+	later := start.Add(12 * time.Minute)
+	snap := s.Snapshot(later)
+	if snap.Count != 100 || snap.Quantile(0.99) != 1 {
+		t.Fatalf("old window not expired: count %v p99 %v", snap.Count, snap.Quantile(0.99))
+	}
+	if len(s.Windows) != 1 {
+		t.Fatalf("expected 1 window, got %d", len(s.Windows))
+	}
+
+	if snap := s.Snapshot(start.Add(time.Hour)); snap.Count != 0 {
+		t.Fatalf("everything must expire, got count %v", snap.Count)
+	}
+}
+
+func TestSummary_Validate(t *testing.T) {
+	if _, err := NewSummary(0, 0); err == nil {
+		t.Fatalf("expected error for zero max age")
+	}
+
+	s, _ := NewSummary(time.Minute, 0)
+	s.Observe(1, time.Now())
+	c := s.Clone()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	c.Windows[0].Digest.Count = 5
+	if err := c.Validate(); err == nil {
+		t.Fatalf("expected error for inconsistent digest")
+	}
+	if s.Windows[0].Digest.Count != 1 {
+		t.Fatalf("clone shares state with the original")
+	}
+}
@@ -0,0 +1,175 @@
+package sketch
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+// DefaultCompression trades accuracy for size; a digest keeps
+// roughly this many centroids after compression.
+const DefaultCompression = 100
+
+// Centroid is a group of nearby values represented by their mean.
+type Centroid struct {
+	Mean   float64 `json:"mean"`
+	Weight float64 `json:"weight"`
+}
+
+// TDigest is a mergeable sketch for quantile estimation (Dunning's t-digest).
+// Tails are kept with small centroids, so extreme quantiles stay accurate.
+//
+// New values are appended as single centroids and merged once the list grows
+// past a limit, so Centroids may be temporarily unsorted; the state is still
+// valid and can be serialized at any time.
+type TDigest struct {
+	Compression float64    `json:"compression"`
+	Centroids   []Centroid `json:"centroids"`
+	Count       float64    `json:"count"`
+	Sum         float64    `json:"sum"`
+	Min         float64    `json:"min"`
+	Max         float64    `json:"max"`
+}
+
+// NewTDigest creates an empty digest; non-positive compression uses the default.
+func NewTDigest(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+
+	return &TDigest{Compression: compression}
+}
+
+// Add inserts a single value.
+func (t *TDigest) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	if t.Count == 0 {
+		t.Min, t.Max = v, v
+	}
+	t.Min = math.Min(t.Min, v)
+	t.Max = math.Max(t.Max, v)
+	t.Count++
+	t.Sum += v
+	t.Centroids = append(t.Centroids, Centroid{Mean: v, Weight: 1})
+	if len(t.Centroids) > t.bufferLimit() {
+		t.compress()
+	}
+}
+
+// Merge adds all values summarized by other.
+func (t *TDigest) Merge(other *TDigest) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if t.Count == 0 {
+		t.Min, t.Max = other.Min, other.Max
+	}
+	t.Min = math.Min(t.Min, other.Min)
+	t.Max = math.Max(t.Max, other.Max)
+	t.Count += other.Count
+	t.Sum += other.Sum
+	t.Centroids = append(t.Centroids, other.Centroids...)
+	if len(t.Centroids) > t.bufferLimit() {
+		t.compress()
+	}
+}
+
+// Quantile estimates the q-quantile, 0 <= q <= 1. It returns NaN for an empty digest.
+// Pending values are merged first, so like Add it modifies the digest.
+func (t *TDigest) Quantile(q float64) float64 {
+	if t.Count == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	t.compress()
+	cs := t.Centroids
+	q = math.Max(0, math.Min(1, q))
+	if len(cs) == 1 {
+		return cs[0].Mean
+	}
+
+	// Each centroid is centered at the middle of its cumulative weight;
+	// interpolate between neighbouring centers, and towards Min/Max at the ends.
+	index := q * t.Count
+	if index <= cs[0].Weight/2 {
+		return t.Min + (cs[0].Mean-t.Min)*index/(cs[0].Weight/2)
+	}
+	cumulative := 0.0
+	for i := 0; i < len(cs)-1; i++ {
+		center := cumulative + cs[i].Weight/2
+		next := cumulative + cs[i].Weight + cs[i+1].Weight/2
+		if index <= next {
+			return cs[i].Mean + (cs[i+1].Mean-cs[i].Mean)*(index-center)/(next-center)
+		}
+		cumulative += cs[i].Weight
+	}
+	last := cs[len(cs)-1]
+	center := t.Count - last.Weight/2
+
+	return last.Mean + (t.Max-last.Mean)*(index-center)/(last.Weight/2)
+}
+
+// Clone returns a deep copy.
+func (t *TDigest) Clone() *TDigest {
+	c := *t
+	c.Centroids = slices.Clone(t.Centroids)
+
+	return &c
+}
+
+// Validate checks the consistency of a digest restored from outside.
+func (t *TDigest) Validate() error {
+	if t.Compression <= 0 {
+		return errors.New("t-digest compression must be positive")
+	}
+	total := 0.0
+	for _, c := range t.Centroids {
+		if c.Weight <= 0 || math.IsNaN(c.Mean) || math.IsInf(c.Mean, 0) {
+			return errors.New("t-digest has an invalid centroid")
+		}
+		total += c.Weight
+	}
+	if math.Abs(total-t.Count) > 1e-9*math.Max(1, t.Count) {
+		return errors.New("t-digest count does not match its centroids")
+	}
+
+	return nil
+}
+
+func (t *TDigest) bufferLimit() int {
+	return int(5 * t.Compression)
+}
+
+// compress merges neighbouring centroids while each spans at most one unit
+// of the scale function k(q) = δ/(2π)·asin(2q-1). The scale is steep near
+// q=0 and q=1, so centroids at the tails stay small, and at most about δ
+// centroids remain.
+func (t *TDigest) compress() {
+	if len(t.Centroids) < 2 {
+		return
+	}
+	cs := t.Centroids
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Mean < cs[j].Mean })
+
+	out := cs[:1]
+	seen := 0.0
+	for _, c := range cs[1:] {
+		cur := &out[len(out)-1]
+		merged := cur.Weight + c.Weight
+		if t.scale((seen+merged)/t.Count)-t.scale(seen/t.Count) <= 1 {
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / merged
+			cur.Weight = merged
+
+			continue
+		}
+		seen += cur.Weight
+		out = append(out, c)
+	}
+	t.Centroids = out
+}
+
+func (t *TDigest) scale(q float64) float64 {
+	return t.Compression / (2 * math.Pi) * math.Asin(math.Max(-1, math.Min(1, 2*q-1)))
+}
@@ -0,0 +1,70 @@
+package sketch
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTDigest_Quantile(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	td := NewTDigest(0)
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = rng.ExpFloat64()
+		td.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.01, 0.5, 0.9, 0.99, 0.999} {
+		want := values[int(q*float64(len(values)))]
+		got := td.Quantile(q)
+		// Rank error is what t-digest bounds, and it is tightest at the tails
+		rank := float64(sort.SearchFloat64s(values, got)) / float64(len(values))
+		if math.Abs(rank-q) > 0.01 {
+			t.Fatalf("q=%v: got %v (rank %v), want about %v", q, got, rank, want)
+		}
+	}
+	if td.Quantile(0) != values[0] || td.Quantile(1) != values[len(values)-1] {
+		t.Fatalf("extreme quantiles must be min and max")
+	}
+	if len(td.Centroids) > 5*DefaultCompression {
+		t.Fatalf("digest not compressed: %d centroids", len(td.Centroids))
+	}
+}
+
+func TestTDigest_MergeAndRoundTrip(t *testing.T) {
+	a, b := NewTDigest(50), NewTDigest(50)
+	for i := 1; i <= 1000; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 1000))
+	}
+	a.Merge(b)
+	if a.Count != 2000 || a.Min != 1 || a.Max != 2000 {
+		t.Fatalf("unexpected merged digest: count %v min %v max %v", a.Count, a.Min, a.Max)
+	}
+	if med := a.Quantile(0.5); math.Abs(med-1000) > 20 {
+		t.Fatalf("median of merged digest: got %v", med)
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var restored TDigest
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := restored.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if restored.Quantile(0.9) != a.Quantile(0.9) {
+		t.Fatalf("restored digest differs")
+	}
+
+	if !math.IsNaN(NewTDigest(0).Quantile(0.5)) {
+		t.Fatalf("empty digest must return NaN")
+	}
+}
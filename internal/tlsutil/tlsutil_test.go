@@ -0,0 +1,176 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI is a throwaway CA with a server and a client certificate on disk.
+type testPKI struct {
+	caFile, serverCert, serverKey, clientCert, clientKey string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey := newKey(t)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key := newKey(t)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("issue certificate: %v", err)
+		}
+
+		return der, key
+	}
+
+	p := testPKI{
+		caFile:     filepath.Join(dir, "ca.pem"),
+		serverCert: filepath.Join(dir, "server.pem"),
+		serverKey:  filepath.Join(dir, "server-key.pem"),
+		clientCert: filepath.Join(dir, "client.pem"),
+		clientKey:  filepath.Join(dir, "client-key.pem"),
+	}
+	writePEM(t, p.caFile, "CERTIFICATE", caDER)
+	srvDER, srvKey := issue(2, x509.ExtKeyUsageServerAuth)
+	writePEM(t, p.serverCert, "CERTIFICATE", srvDER)
+	writeKey(t, p.serverKey, srvKey)
+	cliDER, cliKey := issue(3, x509.ExtKeyUsageClientAuth)
+	writePEM(t, p.clientCert, "CERTIFICATE", cliDER)
+	writeKey(t, p.clientKey, cliKey)
+
+	return p
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	return key
+}
+
+func writeKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	writePEM(t, path, "PRIVATE KEY", der)
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func startTLSServer(t *testing.T, p testPKI, clientCA string) *httptest.Server {
+	t.Helper()
+	cfg, err := ServerConfig(p.serverCert, p.serverKey, clientCA)
+	if err != nil {
+		t.Fatalf("server config: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func get(t *testing.T, url, caFile, certFile, keyFile string) error {
+	t.Helper()
+	cfg, err := ClientConfig(caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("client config: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func TestTLS_ServerAuth(t *testing.T) {
+	p := newTestPKI(t)
+	srv := startTLSServer(t, p, "")
+
+	if err := get(t, srv.URL, p.caFile, "", ""); err != nil {
+		t.Fatalf("request with trusted CA failed: %v", err)
+	}
+	// System roots do not know the test CA
+	if err := get(t, srv.URL, "", "", ""); err == nil {
+		t.Fatalf("request without CA bundle must fail")
+	}
+}
+
+func TestTLS_MutualAuth(t *testing.T) {
+	p := newTestPKI(t)
+	srv := startTLSServer(t, p, p.caFile)
+
+	if err := get(t, srv.URL, p.caFile, p.clientCert, p.clientKey); err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	if err := get(t, srv.URL, p.caFile, "", ""); err == nil {
+		t.Fatalf("request without client certificate must fail")
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	if _, err := ServerConfig("", "", ""); err == nil {
+		t.Fatalf("expected error for missing server files")
+	}
+	if _, err := ClientConfig("", "cert.pem", ""); err == nil {
+		t.Fatalf("expected error for client certificate without key")
+	}
+	if _, err := ClientConfig(filepath.Join(t.TempDir(), "missing.pem"), "", ""); err == nil {
+		t.Fatalf("expected error for missing CA bundle")
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS histograms (
+    name  TEXT PRIMARY KEY,
+    state JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS summaries (
+    name  TEXT PRIMARY KEY,
+    state JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sets (
+    name  TEXT PRIMARY KEY,
+    state JSONB NOT NULL
+);
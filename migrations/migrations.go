@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema migrations applied on server start.
+// Files are applied once each, in lexical order of their names.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS